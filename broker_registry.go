@@ -0,0 +1,279 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// defaultBrokerTimeout is how long brokers wait for the other side by
+// default.
+const defaultBrokerTimeout = 5 * time.Second
+
+// BrokerConfig configures the MuxBroker or GRPCBroker of a plugin. It is set
+// with ClientConfig.Broker on the host and ServeConfig.Broker in the plugin.
+type BrokerConfig struct {
+	// Timeout is how long the broker waits for the other side to accept or
+	// dial an ID before giving up on it. It defaults to 5 seconds.
+	Timeout time.Duration
+
+	// Metrics, if set, receives counters of expired and leaked broker IDs,
+	// see BrokerStats.
+	Metrics BrokerMetrics
+}
+
+func (c *BrokerConfig) timeout() time.Duration {
+	if c == nil || c.Timeout <= 0 {
+		return defaultBrokerTimeout
+	}
+	return c.Timeout
+}
+
+// BrokerMetrics receives the broker's metrics. It is satisfied by
+// *metrics.Metrics from github.com/hashicorp/go-metrics.
+//
+// The counters are "go_plugin.broker.expired" and "go_plugin.broker.leaked".
+type BrokerMetrics interface {
+	IncrCounter(key []string, val float32)
+}
+
+var (
+	brokerExpiredKey = []string{"go_plugin", "broker", "expired"}
+	brokerLeakedKey  = []string{"go_plugin", "broker", "leaked"}
+)
+
+// BrokerDirection is whether a brokered connection was accepted or dialled
+// by this side of the broker.
+type BrokerDirection string
+
+const (
+	BrokerAccept BrokerDirection = "accept"
+	BrokerDial   BrokerDirection = "dial"
+)
+
+// BrokerConn describes a listener or connection open on a broker, as listed
+// by Connections.
+type BrokerConn struct {
+	// ID is the broker ID of the listener or connection.
+	ID uint32
+
+	// Direction is BrokerAccept for listeners and accepted connections, and
+	// BrokerDial for dialled connections.
+	Direction BrokerDirection
+
+	// Addr is the address of the listener or of the dialled peer. It is nil
+	// if unknown.
+	Addr net.Addr
+
+	// Created is when the listener or connection was opened.
+	Created time.Time
+}
+
+// Age returns how long the listener or connection has been open.
+func (c BrokerConn) Age() time.Duration {
+	return time.Since(c.Created)
+}
+
+// BrokerStats are counters of a broker's IDs.
+type BrokerStats struct {
+	// Active is the number of listeners and connections currently open.
+	Active int
+
+	// Expired counts the IDs this side waited for, in Accept or Dial, that
+	// the other side never connected to within the timeout.
+	Expired uint64
+
+	// Leaked counts the IDs the other side offered a connection for that
+	// this side never accepted or dialled within the timeout. They usually
+	// are listeners left open by the other side.
+	Leaked uint64
+}
+
+// brokerRegistry tracks the listeners and connections of a broker.
+type brokerRegistry struct {
+	config *BrokerConfig
+
+	mu      sync.Mutex
+	entries map[*brokerEntry]struct{}
+	expired uint64
+	leaked  uint64
+}
+
+type brokerEntry struct {
+	conn  BrokerConn
+	close func() error
+}
+
+func newBrokerRegistry(config *BrokerConfig) *brokerRegistry {
+	return &brokerRegistry{
+		config:  config,
+		entries: make(map[*brokerEntry]struct{}),
+	}
+}
+
+func (r *brokerRegistry) timeout() time.Duration {
+	return r.config.timeout()
+}
+
+// add registers a listener or connection, closed by closeFn. The returned
+// function removes it from the registry.
+func (r *brokerRegistry) add(id uint32, dir BrokerDirection, addr net.Addr, closeFn func() error) func() {
+	e := &brokerEntry{
+		conn: BrokerConn{
+			ID:        id,
+			Direction: dir,
+			Addr:      addr,
+			Created:   time.Now(),
+		},
+		close: closeFn,
+	}
+
+	r.mu.Lock()
+	r.entries[e] = struct{}{}
+	r.mu.Unlock()
+
+	return func() {
+		r.mu.Lock()
+		delete(r.entries, e)
+		r.mu.Unlock()
+	}
+}
+
+// trackConn registers conn, and removes it when it is closed.
+func (r *brokerRegistry) trackConn(id uint32, dir BrokerDirection, conn net.Conn) net.Conn {
+	t := &trackedConn{Conn: conn}
+	t.remove = r.add(id, dir, conn.RemoteAddr(), t.Close)
+	return t
+}
+
+// trackListener registers ln, and removes it when it is closed.
+func (r *brokerRegistry) trackListener(id uint32, ln net.Listener) net.Listener {
+	t := &trackedListener{Listener: ln}
+	t.remove = r.add(id, BrokerAccept, ln.Addr(), t.Close)
+	return t
+}
+
+// trackClientConn registers conn, and removes it once it is shut down.
+func (r *brokerRegistry) trackClientConn(id uint32, addr net.Addr, conn *grpc.ClientConn) {
+	remove := r.add(id, BrokerDial, addr, conn.Close)
+	go func() {
+		defer remove()
+		for s := conn.GetState(); s != connectivity.Shutdown; s = conn.GetState() {
+			conn.WaitForStateChange(context.Background(), s)
+		}
+	}()
+}
+
+// list returns the open listeners and connections, sorted by ID.
+func (r *brokerRegistry) list() []BrokerConn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conns := make([]BrokerConn, 0, len(r.entries))
+	for e := range r.entries {
+		conns = append(conns, e.conn)
+	}
+	sort.Slice(conns, func(i, j int) bool {
+		if conns[i].ID != conns[j].ID {
+			return conns[i].ID < conns[j].ID
+		}
+		return conns[i].Created.Before(conns[j].Created)
+	})
+	return conns
+}
+
+// closeID closes all listeners and connections for the given ID.
+func (r *brokerRegistry) closeID(id uint32) error {
+	r.mu.Lock()
+	var closers []func() error
+	for e := range r.entries {
+		if e.conn.ID == id {
+			closers = append(closers, e.close)
+		}
+	}
+	r.mu.Unlock()
+
+	var errs []error
+	for _, c := range closers {
+		if err := c(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *brokerRegistry) stats() BrokerStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return BrokerStats{
+		Active:  len(r.entries),
+		Expired: r.expired,
+		Leaked:  r.leaked,
+	}
+}
+
+// expire records that the other side never connected to id.
+func (r *brokerRegistry) expire(id uint32) {
+	r.mu.Lock()
+	r.expired++
+	r.mu.Unlock()
+
+	log.Printf("[DEBUG] plugin: broker ID %d expired waiting for the other side", id)
+	if r.config != nil && r.config.Metrics != nil {
+		r.config.Metrics.IncrCounter(brokerExpiredKey, 1)
+	}
+}
+
+// leak records that the connection offered by the other side for id was
+// never picked up.
+func (r *brokerRegistry) leak(id uint32) {
+	r.mu.Lock()
+	r.leaked++
+	r.mu.Unlock()
+
+	log.Printf("[WARN] plugin: broker ID %d was offered by the other side but never used", id)
+	if r.config != nil && r.config.Metrics != nil {
+		r.config.Metrics.IncrCounter(brokerLeakedKey, 1)
+	}
+}
+
+// trackedConn is a net.Conn removed from its registry when closed.
+type trackedConn struct {
+	net.Conn
+	remove func()
+	once   sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(c.remove)
+	return c.Conn.Close()
+}
+
+// trackedListener is a net.Listener removed from its registry when closed.
+type trackedListener struct {
+	net.Listener
+	remove func()
+	once   sync.Once
+}
+
+func (l *trackedListener) Close() error {
+	l.once.Do(l.remove)
+	return l.Listener.Close()
+}
+
+// errBrokerIDClosed is returned when waiting on an ID closed with CloseID.
+func errBrokerIDClosed(id uint32) error {
+	return fmt.Errorf("broker ID %d was closed", id)
+}
@@ -0,0 +1,347 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/yamux"
+	"google.golang.org/grpc"
+)
+
+// testBrokerMetrics records the counters of a broker.
+type testBrokerMetrics struct {
+	sync.Mutex
+	counters map[string]float32
+}
+
+func (m *testBrokerMetrics) IncrCounter(key []string, val float32) {
+	m.Lock()
+	defer m.Unlock()
+	if m.counters == nil {
+		m.counters = make(map[string]float32)
+	}
+	m.counters[strings.Join(key, ".")] += val
+}
+
+func (m *testBrokerMetrics) counter(key string) float32 {
+	m.Lock()
+	defer m.Unlock()
+	return m.counters[key]
+}
+
+// testMuxBrokers returns a pair of connected MuxBrokers.
+func testMuxBrokers(t *testing.T, config *BrokerConfig) (*MuxBroker, *MuxBroker) {
+	clientConn, serverConn := TestConn(t)
+
+	clientMux, err := yamux.Client(clientConn, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	serverMux, err := yamux.Server(serverConn, nil)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	client := newMuxBroker(clientMux, config)
+	server := newMuxBroker(serverMux, config)
+	go client.Run()
+	go server.Run()
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+
+	return client, server
+}
+
+func TestMuxBroker_connections(t *testing.T) {
+	client, server := testMuxBrokers(t, nil)
+
+	id := server.NextId()
+	acceptCh := make(chan error, 1)
+	go func() {
+		conn, err := server.Accept(id)
+		if err == nil {
+			// Hold the connection until the client closes it.
+			_, _ = conn.Read(make([]byte, 1))
+			_ = conn.Close()
+		}
+		acceptCh <- err
+	}()
+
+	conn, err := client.Dial(id)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	conns := client.Connections()
+	if len(conns) != 1 || conns[0].ID != id || conns[0].Direction != BrokerDial {
+		t.Fatalf("bad: %#v", conns)
+	}
+	if stats := client.Stats(); stats.Active != 1 {
+		t.Fatalf("bad: %#v", stats)
+	}
+
+	if err := client.CloseID(id); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if conns := client.Connections(); len(conns) != 0 {
+		t.Fatalf("bad: %#v", conns)
+	}
+	if _, err := conn.Write([]byte("x")); err == nil {
+		t.Fatal("expected write to a closed connection to fail")
+	}
+
+	if err := <-acceptCh; err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if conns := server.Connections(); len(conns) != 0 {
+		t.Fatalf("bad: %#v", conns)
+	}
+}
+
+func TestMuxBroker_closeIDPending(t *testing.T) {
+	_, server := testMuxBrokers(t, nil)
+
+	id := server.NextId()
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := server.Accept(id)
+		errCh <- err
+	}()
+
+	// Wait for Accept to start waiting.
+	for {
+		server.Lock()
+		_, ok := server.streams[id]
+		server.Unlock()
+		if ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := server.CloseID(id); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	select {
+	case err := <-errCh:
+		if err == nil || !strings.Contains(err.Error(), "was closed") {
+			t.Fatalf("bad: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Accept didn't return after CloseID")
+	}
+}
+
+func TestMuxBroker_expiredAndLeaked(t *testing.T) {
+	metrics := new(testBrokerMetrics)
+	client, server := testMuxBrokers(t, &BrokerConfig{
+		Timeout: 50 * time.Millisecond,
+		Metrics: metrics,
+	})
+
+	// Nobody dials this ID.
+	if _, err := server.Accept(server.NextId()); err == nil {
+		t.Fatal("expected timeout")
+	}
+	if stats := server.Stats(); stats.Expired != 1 {
+		t.Fatalf("bad: %#v", stats)
+	}
+	if v := metrics.counter("go_plugin.broker.expired"); v != 1 {
+		t.Fatalf("bad: %v", v)
+	}
+
+	// Nobody accepts this ID, so the server closes the stream.
+	if _, err := client.Dial(server.NextId()); err == nil {
+		t.Fatal("expected error")
+	}
+	if stats := server.Stats(); stats.Leaked != 1 {
+		t.Fatalf("bad: %#v", stats)
+	}
+	if v := metrics.counter("go_plugin.broker.leaked"); v != 1 {
+		t.Fatalf("bad: %v", v)
+	}
+}
+
+func TestGRPCBroker_connections(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		testGRPCBrokerConnections(t, false)
+	})
+	t.Run("mux", func(t *testing.T) {
+		testGRPCBrokerConnections(t, true)
+	})
+}
+
+func testGRPCBrokerConnections(t *testing.T, multiplex bool) {
+	client, server := TestPluginGRPCConn(t, multiplex, map[string]Plugin{
+		"test": new(testGRPCInterfacePlugin),
+	})
+	defer func() { _ = client.Close() }()
+	defer server.Stop()
+
+	id := server.broker.NextId()
+	ln, err := server.broker.Accept(id)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	s := grpc.NewServer()
+	go func() { _ = s.Serve(ln) }()
+	defer s.Stop()
+
+	conn, err := client.broker.Dial(id)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	conns := server.broker.Connections()
+	if len(conns) != 1 || conns[0].ID != id || conns[0].Direction != BrokerAccept || conns[0].Addr == nil {
+		t.Fatalf("bad: %#v", conns)
+	}
+	conns = client.broker.Connections()
+	if len(conns) != 1 || conns[0].ID != id || conns[0].Direction != BrokerDial {
+		t.Fatalf("bad: %#v", conns)
+	}
+
+	if err := server.broker.CloseID(id); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if stats := server.broker.Stats(); stats.Active != 0 {
+		t.Fatalf("bad: %#v", stats)
+	}
+
+	// The client connection is removed once it is closed.
+	_ = conn.Close()
+	deadline := time.Now().Add(time.Second)
+	for client.broker.Stats().Active != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("bad: %#v", client.broker.Connections())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGRPCBroker_closeIDPending(t *testing.T) {
+	t.Run("dial", func(t *testing.T) {
+		client, server := TestPluginGRPCConn(t, false, map[string]Plugin{
+			"test": new(testGRPCInterfacePlugin),
+		})
+		defer func() { _ = client.Close() }()
+		defer server.Stop()
+
+		// The plugin never accepts this ID.
+		id := client.broker.NextId()
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := client.broker.Dial(id)
+			errCh <- err
+		}()
+
+		// Wait for Dial to start waiting.
+		for {
+			client.broker.Lock()
+			_, ok := client.broker.clientStreams[id]
+			client.broker.Unlock()
+			if ok {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if err := client.broker.CloseID(id); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		select {
+		case err := <-errCh:
+			if err == nil || !strings.Contains(err.Error(), "was closed") {
+				t.Fatalf("bad: %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Dial didn't return after CloseID")
+		}
+	})
+
+	t.Run("accept", func(t *testing.T) {
+		client, server := TestPluginGRPCConn(t, true, map[string]Plugin{
+			"test": new(testGRPCInterfacePlugin),
+		})
+		defer func() { _ = client.Close() }()
+		defer server.Stop()
+
+		// The host never dials this ID.
+		id := server.broker.NextId()
+		ln, err := server.broker.Accept(id)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := ln.Accept()
+			errCh <- err
+		}()
+
+		if err := server.broker.CloseID(id); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		select {
+		case err := <-errCh:
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Accept didn't return after CloseID")
+		}
+	})
+}
+
+func TestGRPCBroker_expiredAndLeaked(t *testing.T) {
+	client, server := TestPluginGRPCConn(t, false, map[string]Plugin{
+		"test": new(testGRPCInterfacePlugin),
+	})
+	defer func() { _ = client.Close() }()
+	defer server.Stop()
+
+	metrics := new(testBrokerMetrics)
+	client.broker.registry.config = &BrokerConfig{
+		Timeout: 50 * time.Millisecond,
+		Metrics: metrics,
+	}
+
+	// The plugin never accepts this ID.
+	if _, err := client.broker.Dial(client.broker.NextId()); err == nil {
+		t.Fatal("expected timeout")
+	}
+	if stats := client.broker.Stats(); stats.Expired != 1 {
+		t.Fatalf("bad: %#v", stats)
+	}
+
+	// The plugin listens on this ID, but the host never dials it.
+	ln, err := server.broker.Accept(server.broker.NextId())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer func() { _ = ln.Close() }()
+
+	deadline := time.Now().Add(time.Second)
+	for client.broker.Stats().Leaked != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("bad: %#v", client.broker.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if v := metrics.counter("go_plugin.broker.leaked"); v != 1 {
+		t.Fatalf("bad: %v", v)
+	}
+	if v := metrics.counter("go_plugin.broker.expired"); v != 1 {
+		t.Fatalf("bad: %v", v)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"hash"
@@ -37,11 +38,6 @@ import (
 // read by using sync/atomic.
 var Killed uint32 = 0
 
-// This is a slice of the "managed" clients which are cleaned up when
-// calling Cleanup
-var managedClients = make([]*Client, 0, 5)
-var managedClientsLock sync.Mutex
-
 // Error types
 var (
 	// ErrProcessNotFound is returned when a client is instantiated to
@@ -89,6 +85,7 @@ const defaultPluginLogBufferSize = 64 * 1024
 type Client struct {
 	config            *ClientConfig
 	exited            bool
+	exitErr           error
 	l                 sync.Mutex
 	address           net.Addr
 	runner            runner.AttachedRunner
@@ -96,7 +93,7 @@ type Client struct {
 	protocol          Protocol
 	logger            hclog.Logger
 	doneCtx           context.Context
-	ctxCancel         context.CancelFunc
+	ctxCancel         context.CancelCauseFunc
 	negotiatedVersion int
 
 	// clientWaitGroup is used to manage the lifecycle of the plugin management
@@ -115,6 +112,23 @@ type Client struct {
 
 	grpcMuxerOnce sync.Once
 	grpcMuxer     *grpcmux.GRPCClientMuxer
+
+	// grpcMux is true if the gRPC broker is multiplexed, and grpcMuxStreamIDs
+	// if the plugin starts multiplexed connections with their stream ID.
+	grpcMux          bool
+	grpcMuxStreamIDs bool
+
+	// autoMTLS holds the certificate material generated and negotiated for
+	// AutoMTLS, so that it can be returned as part of ReattachConfig.
+	autoMTLS *ReattachTLSConfig
+
+	// idle tracks the uses of the plugin if ClientConfig.IdleStopTimeout is
+	// set, and cmdTemplate is a copy of the command to restart it with.
+	// restartLock is held for writing while an idle plugin is stopped, and
+	// for reading while the plugin is started or dispensed.
+	idle        *idleTracker
+	cmdTemplate *exec.Cmd
+	restartLock sync.RWMutex
 }
 
 // NegotiatedVersion returns the protocol version negotiated with the server.
@@ -163,6 +177,13 @@ type ClientConfig struct {
 	Cmd      *exec.Cmd
 	Reattach *ReattachConfig
 
+	// ReattachName, if set, is looked up in the map of reattach
+	// configurations held by the EnvReattachPlugins environment variable. If
+	// an entry is found, the client reattaches to it instead of using Cmd or
+	// RunnerFunc. This lets plugin authors run a plugin under a debugger and
+	// have any host attach to it, without the host needing its own flags.
+	ReattachName string
+
 	// RunnerFunc allows consumers to provide their own implementation of
 	// runner.Runner and control the context within which a plugin is executed.
 	// The cmd argument will have been copied from the config and populated with
@@ -182,6 +203,9 @@ type ClientConfig struct {
 	// it will automatically be cleaned up. Otherwise, the client
 	// user is fully responsible for making sure to Kill all plugin
 	// clients. By default the client is _not_ managed.
+	//
+	// Managed clients are shared by the whole program. Use a Manager to
+	// manage a set of clients separately.
 	Managed bool
 
 	// The minimum and maximum port to use for communicating with
@@ -193,6 +217,20 @@ type ClientConfig struct {
 	// has started successfully.
 	StartTimeout time.Duration
 
+	// IdleStopTimeout, if set, stops the plugin once no call to it was in
+	// flight for that long. The plugin is then started again by the next
+	// call to Client, ClientContext or DispenseContext, like it is started
+	// by the first one.
+	//
+	// Stopping the plugin closes the ClientProtocol returned by Client, and
+	// calls through plugins dispensed from it fail. Only a new Client or
+	// DispenseContext call restarts the plugin, so plugins should be
+	// dispensed again for each use, for example with DispenseContext.
+	//
+	// gRPC, net/rpc and JSON-RPC calls to dispensed plugins are tracked.
+	// IdleStopTimeout is ignored for reattached plugins.
+	IdleStopTimeout time.Duration
+
 	// If non-nil, then the stderr of the client will be written to here
 	// (as well as the log). This is the original os.Stderr of the subprocess.
 	// This isn't the output of synced stderr.
@@ -216,6 +254,9 @@ type ClientConfig struct {
 	// If this isn't set at all (nil value), then only net/rpc is accepted.
 	// This is done for legacy reasons. You must explicitly opt-in to
 	// new protocols.
+	//
+	// The list is in order of preference. Plugins that serve several
+	// protocols (see ServeConfig.Protocols) use the first one they support.
 	AllowedProtocols []Protocol
 
 	// Logger is the logger that the client will used. If none is provided,
@@ -246,7 +287,9 @@ type ClientConfig struct {
 	// TLSProvider, because AutoMTLS implies that a new certificate and tls
 	// configuration will be generated at startup.
 	//
-	// You cannot Reattach to a server with this option enabled.
+	// The negotiated certificates are included in the ReattachConfig returned
+	// by Client.ReattachConfig, so that another client can reattach to the
+	// plugin over the same mTLS connection.
 	AutoMTLS bool
 
 	// GRPCDialOptions allows plugin users to pass custom grpc.DialOption
@@ -260,13 +303,22 @@ type ClientConfig struct {
 	// go-plugin library currently only includes a Go implementation for the
 	// server (i.e. plugin) side of gRPC broker multiplexing.
 	//
-	// Does not support reattaching.
+	// When reattaching, multiplexing follows ReattachConfig.GRPCBrokerMultiplex,
+	// and setting this requires it. Plugins served in test or debug mode
+	// multiplex the broker if ServeTestConfig.GRPCBrokerMultiplex or
+	// ServeDebugConfig.GRPCBrokerMultiplex is set.
 	//
-	// Multiplexed gRPC streams MUST be established sequentially, i.e. after
-	// calling AcceptAndServe from one side, wait for the other side to Dial
-	// before calling AcceptAndServe again.
+	// With plugins built against older versions of go-plugin, multiplexed
+	// gRPC streams MUST be established sequentially, i.e. after calling
+	// AcceptAndServe from one side, wait for the other side to Dial before
+	// calling AcceptAndServe again. Newer plugins start each stream with its
+	// ID, so streams can be established concurrently.
 	GRPCBrokerMultiplex bool
 
+	// Broker configures the host's side of the MuxBroker or GRPCBroker,
+	// such as how long it waits for the plugin. If nil, defaults are used.
+	Broker *BrokerConfig
+
 	// SkipHostEnv allows plugins to run without inheriting the parent process'
 	// environment variables.
 	SkipHostEnv bool
@@ -312,6 +364,53 @@ type ReattachConfig struct {
 	// process and instead will rely on the plugin to terminate itself. This
 	// should not be used in non-test environments.
 	Test bool
+
+	// TLS is the certificate material used to rebuild the client's TLS
+	// configuration when reattaching to a plugin that was started with
+	// AutoMTLS (or served with ServeTestConfig.AutoMTLS). If this is nil,
+	// the reattached connection does not use AutoMTLS.
+	TLS *ReattachTLSConfig
+
+	// GRPCBrokerMultiplex is set to true if the plugin multiplexes the gRPC
+	// broker over its connection (see ClientConfig.GRPCBrokerMultiplex).
+	// The reattached client then multiplexes the broker as well.
+	GRPCBrokerMultiplex bool
+}
+
+// ReattachTLSConfig is the certificate material needed to reattach to a
+// plugin using AutoMTLS. All values are PEM encoded.
+type ReattachTLSConfig struct {
+	// ClientCert and ClientKey are the certificate and private key the
+	// client presents to the plugin.
+	ClientCert []byte
+	ClientKey  []byte
+
+	// ServerCert is the certificate the plugin presents to the client. It
+	// is trusted as the only root CA when connecting.
+	ServerCert []byte
+}
+
+// tlsConfig builds the client TLS configuration for the certificate
+// material, equivalent to the one negotiated by AutoMTLS.
+func (r *ReattachTLSConfig) tlsConfig() (*tls.Config, error) {
+	cert, err := tls.X509KeyPair(r.ClientCert, r.ClientKey)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing client certificate: %w", err)
+	}
+
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(r.ServerCert) {
+		return nil, errors.New("error parsing server certificate")
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+		ServerName:   "localhost",
+		RootCAs:      certPool,
+		ClientCAs:    certPool,
+	}, nil
 }
 
 // SecureConfig is used to configure a client to verify the integrity of an
@@ -365,21 +464,9 @@ func CleanupClients() {
 	// Set the killed to true so that we don't get unexpected panics
 	atomic.StoreUint32(&Killed, 1)
 
-	// Kill all the managed clients in parallel and use a WaitGroup
-	// to wait for them all to finish up.
-	var wg sync.WaitGroup
-	managedClientsLock.Lock()
-	for _, client := range managedClients {
-		wg.Add(1)
-
-		go func(client *Client) {
-			client.Kill()
-			wg.Done()
-		}(client)
-	}
-	managedClientsLock.Unlock()
-
-	wg.Wait()
+	// Kill all the managed clients in parallel and wait for them all to
+	// finish up.
+	_ = defaultManager.killAll(context.Background(), false)
 }
 
 // NewClient creates a new plugin client which manages the lifecycle of an external
@@ -430,10 +517,14 @@ func NewClient(config *ClientConfig) (c *Client) {
 		config: config,
 		logger: config.Logger,
 	}
+	if config.IdleStopTimeout > 0 {
+		c.idle = newIdleTracker(config.IdleStopTimeout, c.stopIdle)
+		if config.Cmd != nil {
+			c.cmdTemplate = cloneCmd(config.Cmd)
+		}
+	}
 	if config.Managed {
-		managedClientsLock.Lock()
-		managedClients = append(managedClients, c)
-		managedClientsLock.Unlock()
+		defaultManager.add(c)
 	}
 
 	return
@@ -441,9 +532,30 @@ func NewClient(config *ClientConfig) (c *Client) {
 
 // Client returns the protocol client for this connection.
 //
-// Subsequent calls to this will return the same client.
+// Subsequent calls to this will return the same client, unless the plugin
+// was stopped because of ClientConfig.IdleStopTimeout in between.
 func (c *Client) Client() (ClientProtocol, error) {
-	_, err := c.Start()
+	return c.ClientContext(context.Background())
+}
+
+// ClientContext is Client, but starting the plugin and connecting to it are
+// bounded by ctx, and ctx is passed on to the protocol. Once the client is
+// created, it isn't affected by ctx anymore.
+func (c *Client) ClientContext(ctx context.Context) (ClientProtocol, error) {
+	c.restartLock.RLock()
+	defer c.restartLock.RUnlock()
+
+	client, err := c.clientContext(ctx)
+	if err == nil && c.idle != nil {
+		c.idle.touch()
+	}
+	return client, err
+}
+
+// clientContext is ClientContext, without waiting for an idle plugin to be
+// stopped.
+func (c *Client) clientContext(ctx context.Context) (ClientProtocol, error) {
+	_, err := c.startContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -455,17 +567,26 @@ func (c *Client) Client() (ClientProtocol, error) {
 		return c.client, nil
 	}
 
-	switch c.protocol {
-	case ProtocolNetRPC:
-		c.client, err = newRPCClient(c)
-
-	case ProtocolGRPC:
-		c.client, err = newGRPCClient(c.doneCtx, c)
-
-	default:
+	factory, ok := clientProtocolFactory(c.protocol)
+	if !ok {
 		return nil, fmt.Errorf("unknown server protocol: %s", c.protocol)
 	}
 
+	c.client, err = factory(&ClientProtocolConfig{
+		Addr: c.address,
+		Dial: func(ctx context.Context) (net.Conn, error) {
+			return c.dialer(ctx, "")
+		},
+		Context:    ctx,
+		TLSConfig:  c.config.TLSConfig,
+		Plugins:    c.config.Plugins,
+		SyncStdout: c.config.SyncStdout,
+		SyncStderr: c.config.SyncStderr,
+		Logger:     c.logger,
+		DoneCtx:    c.doneCtx,
+		client:     c,
+	})
+
 	if err != nil {
 		c.client = nil
 		return nil, err
@@ -474,6 +595,47 @@ func (c *Client) Client() (ClientProtocol, error) {
 	return c.client, nil
 }
 
+// DispenseContext creates the client if needed, like ClientContext, and
+// dispenses the plugin with the given name. If the protocol client
+// implements ContextDispenser, ctx is passed on to it, so it can bound the
+// call and pass values such as trace spans to the plugin's client.
+func (c *Client) DispenseContext(ctx context.Context, name string) (interface{}, error) {
+	c.restartLock.RLock()
+	defer c.restartLock.RUnlock()
+
+	client, err := c.clientContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c.idle != nil {
+		c.idle.touch()
+	}
+
+	if d, ok := client.(ContextDispenser); ok {
+		return d.DispenseContext(ctx, name)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return client.Dispense(name)
+}
+
+// withValues returns a context cancelled along with ctx, with the values of
+// values. It's used to pass request scoped values to long-lived plugin
+// clients.
+func withValues(ctx, values context.Context) context.Context {
+	return valuesContext{Context: ctx, values: values}
+}
+
+type valuesContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c valuesContext) Value(key interface{}) interface{} {
+	return c.values.Value(key)
+}
+
 // Tells whether or not the underlying process has exited.
 func (c *Client) Exited() bool {
 	c.l.Lock()
@@ -496,6 +658,25 @@ func (c *Client) killed() bool {
 //
 // This method can safely be called multiple times.
 func (c *Client) Kill() {
+	c.KillContext(context.Background())
+}
+
+// KillContext is Kill, but the plugin is only given until ctx is done to
+// exit gracefully, after which it is killed forcefully. KillContext still
+// blocks until the process exits.
+func (c *Client) KillContext(ctx context.Context) {
+	// The plugin isn't restarted after being killed. Wait for an idle
+	// plugin being stopped, so that the client isn't reset afterwards.
+	if c.idle != nil {
+		c.restartLock.Lock()
+		defer c.restartLock.Unlock()
+		c.idle.close()
+	}
+	c.kill(ctx)
+}
+
+// kill is KillContext, but allows an idle plugin to be started again.
+func (c *Client) kill(ctx context.Context) {
 	// Grab a lock to read some private fields.
 	c.l.Lock()
 	runner := c.runner
@@ -530,7 +711,7 @@ func (c *Client) Kill() {
 	graceful := false
 	if addr != nil {
 		// Close the client to cleanly exit the process.
-		client, err := c.Client()
+		client, err := c.clientContext(ctx)
 		if err == nil {
 			err = client.Close()
 
@@ -556,13 +737,15 @@ func (c *Client) Kill() {
 		case <-c.doneCtx.Done():
 			c.logger.Debug("plugin exited")
 			return
+		case <-ctx.Done():
 		case <-time.After(2 * time.Second):
 		}
 	}
 
-	// If graceful exiting failed, just kill it
+	// If graceful exiting failed, just kill it. This must happen even if
+	// ctx is done.
 	c.logger.Warn("plugin failed to exit gracefully")
-	if err := runner.Kill(context.Background()); err != nil {
+	if err := runner.Kill(context.WithoutCancel(ctx)); err != nil {
 		c.logger.Debug("error killing plugin", "error", err)
 	}
 
@@ -576,14 +759,50 @@ func (c *Client) Kill() {
 //
 // This method is safe to call multiple times. Subsequent calls have no effect.
 // Once a client has been started once, it cannot be started again, even if
-// it was killed.
+// it was killed, unless it was stopped because of ClientConfig.IdleStopTimeout.
 func (c *Client) Start() (addr net.Addr, err error) {
+	return c.StartContext(context.Background())
+}
+
+// StartContext is Start, but gives up starting the plugin once ctx is done,
+// in addition to StartTimeout, and kills the plugin process if it was
+// already launched. ctx is passed on to the Runner.
+func (c *Client) StartContext(ctx context.Context) (addr net.Addr, err error) {
+	c.restartLock.RLock()
+	defer c.restartLock.RUnlock()
+
+	addr, err = c.startContext(ctx)
+	if err == nil && c.idle != nil {
+		c.idle.touch()
+	}
+	return addr, err
+}
+
+// startContext is StartContext, without waiting for an idle plugin to be
+// stopped.
+func (c *Client) startContext(ctx context.Context) (addr net.Addr, err error) {
 	c.l.Lock()
 	defer c.l.Unlock()
 
 	if c.address != nil {
 		return c.address, nil
 	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if c.config.Reattach == nil && c.config.ReattachName != "" {
+		configs, err := ReattachConfigsFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		if reattach, ok := configs[c.config.ReattachName]; ok {
+			c.logger.Debug("reattaching to plugin from environment", "name", c.config.ReattachName)
+			c.config.Reattach = reattach
+			c.config.Cmd = nil
+			c.config.RunnerFunc = nil
+		}
+	}
 
 	// If one of cmd or reattach isn't set, then it is an error. We wrap
 	// this in a {} for scoping reasons, and hopeful that the escape
@@ -607,8 +826,9 @@ func (c *Client) Start() (addr net.Addr, err error) {
 			return nil, ErrSecureConfigAndReattach
 		}
 
-		if c.config.GRPCBrokerMultiplex && c.config.Reattach != nil {
-			return nil, fmt.Errorf("gRPC broker multiplexing is not supported with Reattach config")
+		if c.config.GRPCBrokerMultiplex && c.config.Reattach != nil && !c.config.Reattach.GRPCBrokerMultiplex {
+			return nil, fmt.Errorf("%w; the plugin must be served with gRPC broker multiplexing "+
+				"to reattach with it", ErrGRPCBrokerMuxNotSupported)
 		}
 	}
 
@@ -640,9 +860,12 @@ func (c *Client) Start() (addr net.Addr, err error) {
 		fmt.Sprintf("PLUGIN_MIN_PORT=%d", c.config.MinPort),
 		fmt.Sprintf("PLUGIN_MAX_PORT=%d", c.config.MaxPort),
 		fmt.Sprintf("PLUGIN_PROTOCOL_VERSIONS=%s", strings.Join(versionStrings, ",")),
+		fmt.Sprintf("%s=%s", envPluginProtocols, joinProtocols(c.config.AllowedProtocols)),
 	}
 	if c.config.GRPCBrokerMultiplex {
-		env = append(env, fmt.Sprintf("%s=true", envMultiplexGRPC))
+		env = append(env,
+			fmt.Sprintf("%s=true", envMultiplexGRPC),
+			fmt.Sprintf("%s=true", envMultiplexGRPCStreamIDs))
 	}
 
 	cmd := c.config.Cmd
@@ -689,6 +912,10 @@ func (c *Client) Start() (addr net.Addr, err error) {
 			MinVersion:   tls.VersionTLS12,
 			ServerName:   "localhost",
 		}
+		c.autoMTLS = &ReattachTLSConfig{
+			ClientCert: certPEM,
+			ClientKey:  keyPEM,
+		}
 	}
 
 	if c.config.UnixSocketConfig != nil {
@@ -730,7 +957,7 @@ func (c *Client) Start() (addr net.Addr, err error) {
 	}
 
 	c.runner = runner
-	startCtx, startCtxCancel := context.WithTimeout(context.Background(), c.config.StartTimeout)
+	startCtx, startCtxCancel := context.WithTimeout(ctx, c.config.StartTimeout)
 	defer startCtxCancel()
 	err = runner.Start(startCtx)
 	if err != nil {
@@ -742,7 +969,7 @@ func (c *Client) Start() (addr net.Addr, err error) {
 		rErr := recover()
 
 		if err != nil || rErr != nil {
-			_ = runner.Kill(context.Background())
+			_ = runner.Kill(context.WithoutCancel(ctx))
 		}
 
 		if rErr != nil {
@@ -751,7 +978,8 @@ func (c *Client) Start() (addr net.Addr, err error) {
 	}()
 
 	// Create a context for when we kill
-	c.doneCtx, c.ctxCancel = context.WithCancel(context.Background())
+	c.doneCtx, c.ctxCancel = context.WithCancelCause(context.Background())
+	cancel := c.ctxCancel
 
 	// Add two to pipesWaitGroup: one for logStderr, one for the goroutine
 	// below that consumes Stdout.  We mustn't continue to Add once we might Wait.
@@ -764,8 +992,10 @@ func (c *Client) Start() (addr net.Addr, err error) {
 
 	c.clientWaitGroup.Add(1)
 	go func() {
+		var err error
+
 		// ensure the context is cancelled when we're done
-		defer c.ctxCancel()
+		defer func() { cancel(&exitCause{err: err}) }()
 
 		defer c.clientWaitGroup.Done()
 
@@ -774,7 +1004,7 @@ func (c *Client) Start() (addr net.Addr, err error) {
 		c.pipesWaitGroup.Wait()
 
 		// Wait for the command to end.
-		err := runner.Wait(context.Background())
+		err = runner.Wait(context.Background())
 		if err != nil {
 			c.logger.Error("plugin process exited", "plugin", runner.Name(), "id", runner.ID(), "error", err.Error())
 		} else {
@@ -788,6 +1018,7 @@ func (c *Client) Start() (addr net.Addr, err error) {
 		c.l.Lock()
 		defer c.l.Unlock()
 		c.exited = true
+		c.exitErr = err
 	}()
 
 	// Start a goroutine that is going to be reading the lines
@@ -829,6 +1060,8 @@ func (c *Client) Start() (addr net.Addr, err error) {
 	select {
 	case <-timeout:
 		err = errors.New("timeout while waiting for plugin to start")
+	case <-ctx.Done():
+		err = fmt.Errorf("plugin start cancelled: %w", ctx.Err())
 	case <-c.doneCtx.Done():
 		err = errors.New("plugin exited before we could connect")
 	case line, ok := <-linesCh:
@@ -841,7 +1074,7 @@ func (c *Client) Start() (addr net.Addr, err error) {
 			if !ok {
 				errText += "\n" + "Failed to read any lines from plugin's stdout"
 			}
-			additionalNotes := runner.Diagnose(context.Background())
+			additionalNotes := runner.Diagnose(context.WithoutCancel(ctx))
 			if additionalNotes != "" {
 				errText += "\n" + additionalNotes
 			}
@@ -940,6 +1173,16 @@ func (c *Client) Start() (addr net.Addr, err error) {
 			} else if !muxSupported {
 				return nil, ErrGRPCBrokerMuxNotSupported
 			}
+			c.grpcMux = true
+
+			// Older plugins don't send the eighth segment, and expect
+			// multiplexed connections without stream IDs.
+			if len(parts) > 7 {
+				c.grpcMuxStreamIDs, err = strconv.ParseBool(parts[7])
+				if err != nil {
+					return nil, fmt.Errorf("error parsing %q as a boolean for gRPC broker stream ID support", parts[7])
+				}
+			}
 		}
 	}
 
@@ -966,10 +1209,26 @@ func (c *Client) loadServerCert(cert string) error {
 
 	c.config.TLSConfig.RootCAs = certPool
 	c.config.TLSConfig.ClientCAs = certPool
+
+	if c.autoMTLS != nil {
+		c.autoMTLS.ServerCert = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: asn1})
+	}
 	return nil
 }
 
 func (c *Client) reattach() (net.Addr, error) {
+	if c.config.Reattach.TLS != nil {
+		if c.config.TLSConfig != nil {
+			return nil, errors.New("only one of TLSConfig or Reattach.TLS can be set")
+		}
+
+		tlsConfig, err := c.config.Reattach.TLS.tlsConfig()
+		if err != nil {
+			return nil, fmt.Errorf("error loading reattach TLS config: %w", err)
+		}
+		c.config.TLSConfig = tlsConfig
+	}
+
 	reattachFunc := c.config.Reattach.ReattachFunc
 	// For backwards compatibility default to cmdrunner.ReattachFunc
 	if reattachFunc == nil {
@@ -982,18 +1241,21 @@ func (c *Client) reattach() (net.Addr, error) {
 	}
 
 	// Create a context for when we kill
-	c.doneCtx, c.ctxCancel = context.WithCancel(context.Background())
+	c.doneCtx, c.ctxCancel = context.WithCancelCause(context.Background())
+	cancel := c.ctxCancel
 
 	c.clientWaitGroup.Add(1)
 	// Goroutine to mark exit status
 	go func(r runner.AttachedRunner) {
 		defer c.clientWaitGroup.Done()
 
+		var err error
+
 		// ensure the context is cancelled when we're done
-		defer c.ctxCancel()
+		defer func() { cancel(&exitCause{err: err}) }()
 
 		// Wait for the process to die
-		_ = r.Wait(context.Background())
+		err = r.Wait(context.Background())
 
 		// Log so we can see it
 		c.logger.Debug("reattached plugin process exited")
@@ -1002,11 +1264,16 @@ func (c *Client) reattach() (net.Addr, error) {
 		c.l.Lock()
 		defer c.l.Unlock()
 		c.exited = true
+		c.exitErr = err
 	}(r)
 
 	// Set the address and protocol
 	c.address = c.config.Reattach.Addr
 	c.protocol = c.config.Reattach.Protocol
+	c.grpcMux = c.config.Reattach.GRPCBrokerMultiplex
+	// Plugins only support multiplexing when reattaching if they support
+	// stream IDs.
+	c.grpcMuxStreamIDs = c.grpcMux
 	if c.protocol == "" {
 		// Default the protocol to net/rpc for backwards compatibility
 		c.protocol = ProtocolNetRPC
@@ -1081,6 +1348,11 @@ func (c *Client) ReattachConfig() *ReattachConfig {
 	reattach := &ReattachConfig{
 		Protocol: c.protocol,
 		Addr:     c.address,
+		TLS:      c.autoMTLS,
+
+		// Reattaching requires stream IDs, which older plugins don't
+		// support even if they multiplex the broker.
+		GRPCBrokerMultiplex: c.grpcMux && c.grpcMuxStreamIDs,
 	}
 
 	if c.config.Cmd != nil && c.config.Cmd.Process != nil {
@@ -1130,7 +1402,7 @@ func (c *Client) dialer(ctx context.Context, _ string) (net.Conn, error) {
 
 	var conn net.Conn
 	if muxer.Enabled() {
-		conn, err = muxer.Dial()
+		conn, err = muxer.Dial(0)
 		if err != nil {
 			return nil, err
 		}
@@ -1151,13 +1423,13 @@ func (c *Client) dialer(ctx context.Context, _ string) (net.Conn, error) {
 }
 
 func (c *Client) getGRPCMuxer(addr net.Addr) (*grpcmux.GRPCClientMuxer, error) {
-	if c.protocol != ProtocolGRPC || !c.config.GRPCBrokerMultiplex {
+	if c.protocol != ProtocolGRPC || !c.grpcMux {
 		return nil, nil
 	}
 
 	var err error
 	c.grpcMuxerOnce.Do(func() {
-		c.grpcMuxer, err = grpcmux.NewGRPCClientMuxer(c.logger, addr)
+		c.grpcMuxer, err = grpcmux.NewGRPCClientMuxer(c.logger, addr, c.grpcMuxStreamIDs)
 	})
 	if err != nil {
 		return nil, err
@@ -666,6 +666,66 @@ func testClient_reattachGRPC(t *testing.T, useReattachFunc bool) {
 	}
 }
 
+func TestClient_reattachAutoMTLS(t *testing.T) {
+	process := helperProcess("test-mtls")
+	c := NewClient(&ClientConfig{
+		Cmd:             process,
+		HandshakeConfig: testVersionedHandshake,
+		VersionedPlugins: map[int]PluginSet{
+			2: testGRPCPluginMap,
+		},
+		AllowedProtocols: []Protocol{ProtocolGRPC},
+		AutoMTLS:         true,
+	})
+	defer c.Kill()
+
+	if _, err := c.Client(); err != nil {
+		t.Fatalf("err should be nil, got %s", err)
+	}
+
+	reattach := c.ReattachConfig()
+	if reattach.TLS == nil {
+		t.Fatal("reattach config should include TLS material")
+	}
+	if len(reattach.TLS.ClientCert) == 0 || len(reattach.TLS.ClientKey) == 0 || len(reattach.TLS.ServerCert) == 0 {
+		t.Fatalf("incomplete TLS material: %#v", reattach.TLS)
+	}
+
+	// Create a new client
+	c = NewClient(&ClientConfig{
+		Reattach:         reattach,
+		HandshakeConfig:  testVersionedHandshake,
+		Plugins:          testGRPCPluginMap,
+		AllowedProtocols: []Protocol{ProtocolGRPC},
+	})
+
+	client, err := c.Client()
+	if err != nil {
+		t.Fatalf("err should be nil, got %s", err)
+	}
+
+	raw, err := client.Dispense("test")
+	if err != nil {
+		t.Fatalf("err should be nil, got %s", err)
+	}
+
+	impl, ok := raw.(testInterface)
+	if !ok {
+		t.Fatalf("bad: %#v", raw)
+	}
+
+	result := impl.Double(21)
+	if result != 42 {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	c.Kill()
+
+	if !c.Exited() {
+		t.Fatal("should say client has exited")
+	}
+}
+
 func TestClient_reattachNotFound(t *testing.T) {
 	// Find a bad pid
 	pid := 5000
@@ -754,6 +814,97 @@ func TestClient_Start_Timeout(t *testing.T) {
 	}
 }
 
+func TestClient_StartContext_cancel(t *testing.T) {
+	c := NewClient(&ClientConfig{
+		Cmd:             helperProcess("start-timeout"),
+		HandshakeConfig: testHandshake,
+		Plugins:         testPluginMap,
+	})
+	defer c.Kill()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := c.StartContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("bad: %v", err)
+	}
+
+	// The plugin process is killed.
+	time.Sleep(100 * time.Millisecond)
+	if !c.Exited() {
+		t.Fatal("should have exited")
+	}
+}
+
+func TestClient_DispenseContext(t *testing.T) {
+	for name, tc := range testProtocols {
+		t.Run(name, func(t *testing.T) {
+			c := NewClient(&ClientConfig{
+				Cmd:              helperProcess(tc.helper),
+				HandshakeConfig:  testHandshake,
+				Plugins:          tc.plugins,
+				AllowedProtocols: []Protocol{ProtocolNetRPC, ProtocolGRPC},
+			})
+			defer c.Kill()
+
+			raw, err := c.DispenseContext(context.Background(), "test")
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if result := raw.(testInterface).Double(21); result != 42 {
+				t.Fatalf("bad: %#v", result)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if _, err := c.DispenseContext(ctx, "test"); !errors.Is(err, context.Canceled) {
+				t.Fatalf("bad: %v", err)
+			}
+		})
+	}
+}
+
+func TestClient_KillContext(t *testing.T) {
+	c := NewClient(&ClientConfig{
+		Cmd:             helperProcess("test-interface"),
+		HandshakeConfig: testHandshake,
+		Plugins:         testPluginMap,
+	})
+	defer c.Kill()
+
+	if _, err := c.Start(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// With no time left to exit gracefully, the plugin is killed.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.KillContext(ctx)
+
+	if !c.Exited() {
+		t.Fatal("should have exited")
+	}
+	if !c.killed() {
+		t.Fatal("process should have been killed")
+	}
+}
+
+func TestWithValues(t *testing.T) {
+	type key struct{}
+	done, cancel := context.WithCancel(context.Background())
+	ctx := withValues(done, context.WithValue(context.Background(), key{}, "span"))
+
+	if v := ctx.Value(key{}); v != "span" {
+		t.Fatalf("bad: %v", v)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("should not be done")
+	}
+	cancel()
+	<-ctx.Done()
+}
+
 func TestClient_Stderr(t *testing.T) {
 	stderr := new(bytes.Buffer)
 	process := helperProcess("stderr")
@@ -0,0 +1,621 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const pluginPackage = "github.com/hashicorp/go-plugin"
+
+// kind is how an argument is passed to the plugin.
+type kind int
+
+const (
+	// kindValue arguments are encoded with encoding/gob.
+	kindValue kind = iota
+	// kindReader arguments are streamed to the plugin over the MuxBroker.
+	kindReader
+	// kindWriter arguments are streamed from the plugin over the MuxBroker.
+	kindWriter
+	// kindInterface arguments are served to the plugin over the MuxBroker.
+	kindInterface
+)
+
+// iface is an interface to generate net/rpc glue for.
+type iface struct {
+	name    string
+	methods []*method
+}
+
+// method is a method of an interface.
+type method struct {
+	name    string
+	params  []*param
+	results []string
+
+	// hasErr is true if the last result, not in results, is an error.
+	hasErr bool
+}
+
+// param is an argument of a method.
+type param struct {
+	typ      string
+	kind     kind
+	variadic bool
+
+	// iface is the name of the interface of kindInterface arguments.
+	iface string
+}
+
+// parsedFile is a Go file of the package with the names of its imports.
+type parsedFile struct {
+	file    *ast.File
+	imports map[string]string
+}
+
+// generator accumulates the generated source.
+type generator struct {
+	buf     bytes.Buffer
+	imports map[string]string
+}
+
+// P prints the arguments followed by a newline.
+func (g *generator) P(v ...interface{}) {
+	fmt.Fprint(&g.buf, v...)
+	fmt.Fprintln(&g.buf)
+}
+
+// generate returns the source of the net/rpc glue for the named interfaces
+// of the package in dir. The output file is skipped when parsing dir.
+func generate(dir string, names []string, output string) ([]byte, error) {
+	files, err := parseDir(dir, output)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+
+	listed := make(map[string]bool)
+	for _, name := range names {
+		listed[name] = true
+	}
+
+	g := &generator{imports: map[string]string{
+		"rpc":    "net/rpc",
+		"plugin": pluginPackage,
+	}}
+	var ifaces []*iface
+	for _, name := range names {
+		it, f, err := findInterface(files, name)
+		if err != nil {
+			return nil, err
+		}
+		i, err := g.parseInterface(name, it, f, files, listed)
+		if err != nil {
+			return nil, err
+		}
+		ifaces = append(ifaces, i)
+	}
+
+	body := &generator{imports: g.imports}
+	for _, i := range ifaces {
+		body.genInterface(i)
+	}
+
+	g.P("// Code generated by go-plugin-netrpc. DO NOT EDIT.")
+	g.P()
+	g.P("package ", files[0].file.Name.Name)
+	g.P()
+	g.P("import (")
+	var localNames []string
+	for name := range g.imports {
+		localNames = append(localNames, name)
+	}
+	// Standard library imports go first.
+	sort.Slice(localNames, func(i, j int) bool {
+		pi, pj := g.imports[localNames[i]], g.imports[localNames[j]]
+		if si, sj := isStd(pi), isStd(pj); si != sj {
+			return si
+		}
+		return pi < pj
+	})
+	std := true
+	for _, name := range localNames {
+		p := g.imports[name]
+		if std && !isStd(p) {
+			std = false
+			g.P()
+		}
+		if name == path.Base(p) {
+			g.P(strconv.Quote(p))
+		} else {
+			g.P(name, " ", strconv.Quote(p))
+		}
+	}
+	g.P(")")
+	g.P()
+	g.buf.Write(body.buf.Bytes())
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+// isStd returns true if p is the path of a standard library package.
+func isStd(p string) bool {
+	return !strings.Contains(strings.Split(p, "/")[0], ".")
+}
+
+// parseDir parses the non-test Go files in dir other than output.
+func parseDir(dir, output string) ([]*parsedFile, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	var files []*parsedFile
+	for _, p := range paths {
+		base := filepath.Base(p)
+		if base == output || strings.HasSuffix(base, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, p, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+
+		imports := make(map[string]string)
+		for _, imp := range f.Imports {
+			p, err := strconv.Unquote(imp.Path.Value)
+			if err != nil {
+				return nil, err
+			}
+			name := path.Base(p)
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			imports[name] = p
+		}
+		files = append(files, &parsedFile{file: f, imports: imports})
+	}
+
+	return files, nil
+}
+
+// findInterface returns the interface type declared as name and its file.
+func findInterface(files []*parsedFile, name string) (*ast.InterfaceType, *parsedFile, error) {
+	for _, f := range files {
+		for _, decl := range f.file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if ts.Name.Name != name {
+					continue
+				}
+				it, ok := ts.Type.(*ast.InterfaceType)
+				if !ok {
+					return nil, nil, fmt.Errorf("%s is not an interface", name)
+				}
+				return it, f, nil
+			}
+		}
+	}
+
+	return nil, nil, fmt.Errorf("interface %s not found", name)
+}
+
+// isInterface returns true if name is an interface declared in the package.
+func isInterface(files []*parsedFile, name string) bool {
+	_, _, err := findInterface(files, name)
+	return err == nil
+}
+
+func (g *generator) parseInterface(name string, it *ast.InterfaceType, f *parsedFile, files []*parsedFile, listed map[string]bool) (*iface, error) {
+	i := &iface{name: name}
+	for _, field := range it.Methods.List {
+		if len(field.Names) == 0 {
+			return nil, fmt.Errorf("%s: embedded interfaces are not supported", name)
+		}
+		ft, ok := field.Type.(*ast.FuncType)
+		if !ok {
+			return nil, fmt.Errorf("%s: type constraints are not supported", name)
+		}
+
+		m := &method{name: field.Names[0].Name}
+		where := name + "." + m.name
+		for _, p := range flatten(ft.Params) {
+			typ := p
+			variadic := false
+			if e, ok := p.(*ast.Ellipsis); ok {
+				typ = e.Elt
+				variadic = true
+			}
+
+			k, ifaceName, err := classify(typ, f, files, listed)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", where, err)
+			}
+			if variadic && k != kindValue {
+				return nil, fmt.Errorf("%s: variadic %s arguments are not supported", where, types.ExprString(typ))
+			}
+			if err := g.addImports(typ, f); err != nil {
+				return nil, fmt.Errorf("%s: %w", where, err)
+			}
+			m.params = append(m.params, &param{
+				typ:      types.ExprString(typ),
+				kind:     k,
+				variadic: variadic,
+				iface:    ifaceName,
+			})
+		}
+
+		results := flatten(ft.Results)
+		if n := len(results); n > 0 {
+			if id, ok := results[n-1].(*ast.Ident); ok && id.Name == "error" {
+				m.hasErr = true
+				results = results[:n-1]
+			}
+		}
+		for _, r := range results {
+			k, _, err := classify(r, f, files, listed)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", where, err)
+			}
+			if k != kindValue {
+				return nil, fmt.Errorf("%s: %s results are not supported", where, types.ExprString(r))
+			}
+			if err := g.addImports(r, f); err != nil {
+				return nil, fmt.Errorf("%s: %w", where, err)
+			}
+			m.results = append(m.results, types.ExprString(r))
+		}
+
+		i.methods = append(i.methods, m)
+	}
+
+	return i, nil
+}
+
+// flatten returns the type of every parameter in fields.
+func flatten(fields *ast.FieldList) []ast.Expr {
+	if fields == nil {
+		return nil
+	}
+
+	var exprs []ast.Expr
+	for _, field := range fields.List {
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			exprs = append(exprs, field.Type)
+		}
+	}
+	return exprs
+}
+
+// classify returns how an argument of type typ is passed to the plugin.
+func classify(typ ast.Expr, f *parsedFile, files []*parsedFile, listed map[string]bool) (kind, string, error) {
+	switch t := typ.(type) {
+	case *ast.SelectorExpr:
+		x, ok := t.X.(*ast.Ident)
+		if !ok {
+			break
+		}
+		switch f.imports[x.Name] + "." + t.Sel.Name {
+		case "io.Reader":
+			return kindReader, "", nil
+		case "io.Writer":
+			return kindWriter, "", nil
+		case "context.Context":
+			return 0, "", fmt.Errorf("context.Context is not supported")
+		}
+
+	case *ast.Ident:
+		if listed[t.Name] {
+			return kindInterface, t.Name, nil
+		}
+		if t.Name == "error" {
+			return 0, "", fmt.Errorf("error arguments are not supported")
+		}
+		if isInterface(files, t.Name) {
+			return 0, "", fmt.Errorf("interface %s must be listed with -type", t.Name)
+		}
+
+	case *ast.ChanType:
+		return 0, "", fmt.Errorf("channels are not supported")
+
+	case *ast.FuncType:
+		return 0, "", fmt.Errorf("functions are not supported")
+
+	case *ast.InterfaceType:
+		if len(t.Methods.List) > 0 {
+			return 0, "", fmt.Errorf("interface literals are not supported")
+		}
+	}
+
+	return kindValue, "", nil
+}
+
+// addImports records the imports of f referenced by typ.
+func (g *generator) addImports(typ ast.Expr, f *parsedFile) error {
+	var err error
+	ast.Inspect(typ, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		x, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+
+		p, ok := f.imports[x.Name]
+		if !ok {
+			err = fmt.Errorf("unknown package %s", x.Name)
+			return false
+		}
+		if other, ok := g.imports[x.Name]; ok && other != p {
+			err = fmt.Errorf("package name %s refers to both %s and %s", x.Name, other, p)
+			return false
+		}
+		g.imports[x.Name] = p
+		return false
+	})
+	return err
+}
+
+func (g *generator) genInterface(i *iface) {
+	pluginName := i.name + "Plugin"
+	clientName := i.name + "RPCClient"
+	serverName := i.name + "RPCServer"
+
+	g.P("// ", pluginName, " is the plugin.Plugin serving and dispensing ", i.name, " over net/rpc.")
+	g.P("type ", pluginName, " struct {")
+	g.P("// Impl is the ", i.name, " served by the plugin.")
+	g.P("Impl ", i.name)
+	g.P("}")
+	g.P()
+	g.P("var _ plugin.Plugin = (*", pluginName, ")(nil)")
+	g.P()
+	g.P("func (p *", pluginName, ") Server(b *plugin.MuxBroker) (interface{}, error) {")
+	g.P("return &", serverName, "{Impl: p.Impl, Broker: b}, nil")
+	g.P("}")
+	g.P()
+	g.P("func (p *", pluginName, ") Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {")
+	g.P("return &", clientName, "{Client: c, Broker: b}, nil")
+	g.P("}")
+	g.P()
+
+	g.P("// ", clientName, " is an implementation of ", i.name, " that talks over RPC.")
+	g.P("type ", clientName, " struct {")
+	g.P("Client *rpc.Client")
+	g.P("Broker *plugin.MuxBroker")
+	g.P("}")
+	g.P()
+	g.P("var _ ", i.name, " = (*", clientName, ")(nil)")
+	g.P()
+	for _, m := range i.methods {
+		g.genClientMethod(i, m)
+	}
+
+	g.P("// ", serverName, " is the RPC server that ", clientName, " talks to, conforming to")
+	g.P("// the requirements of net/rpc.")
+	g.P("type ", serverName, " struct {")
+	g.P("Impl   ", i.name)
+	g.P("Broker *plugin.MuxBroker")
+	g.P("}")
+	g.P()
+	for _, m := range i.methods {
+		g.genServerMethod(i, m)
+	}
+
+	for _, m := range i.methods {
+		g.genMessages(i, m)
+	}
+}
+
+func (g *generator) genClientMethod(i *iface, m *method) {
+	var params []string
+	streams := false
+	for n, p := range m.params {
+		typ := p.typ
+		if p.variadic {
+			typ = "..." + typ
+		}
+		params = append(params, fmt.Sprintf("a%d %s", n, typ))
+		if p.kind == kindReader || p.kind == kindWriter {
+			streams = true
+		}
+	}
+	results := append([]string(nil), m.results...)
+	if m.hasErr {
+		results = append(results, "error")
+	}
+	var returns []string
+	for n := range m.results {
+		returns = append(returns, fmt.Sprintf("reply.R%d", n))
+	}
+
+	sig := "func (c *" + i.name + "RPCClient) " + m.name + "(" + strings.Join(params, ", ") + ")"
+	switch len(results) {
+	case 0:
+	case 1:
+		sig += " " + results[0]
+	default:
+		sig += " (" + strings.Join(results, ", ") + ")"
+	}
+	g.P(sig, " {")
+	g.P("args := &", i.name, m.name, "Args{}")
+	if streams {
+		g.P("var wg sync.WaitGroup")
+		g.imports["sync"] = "sync"
+	}
+	for n, p := range m.params {
+		field := fmt.Sprintf("args.A%d", n)
+		switch p.kind {
+		case kindValue:
+			g.P(field, " = a", n)
+		case kindReader, kindWriter:
+			g.imports["io"] = "io"
+			cp := fmt.Sprintf("io.Copy(conn, a%d)", n)
+			if p.kind == kindWriter {
+				cp = fmt.Sprintf("io.Copy(a%d, conn)", n)
+			}
+			g.P(field, " = c.Broker.NextId()")
+			g.P("wg.Add(1)")
+			g.P("go func(id uint32) {")
+			g.P("defer wg.Done()")
+			g.P("conn, err := c.Broker.Accept(id)")
+			g.P("if err != nil {")
+			g.P("return")
+			g.P("}")
+			g.P("defer conn.Close()")
+			g.P("if a", n, " != nil {")
+			g.P("_, _ = ", cp)
+			g.P("}")
+			g.P("}(", field, ")")
+		case kindInterface:
+			g.P(field, " = c.Broker.NextId()")
+			g.P("go c.Broker.AcceptAndServe(", field, ", &", p.iface, "RPCServer{Impl: a", n, ", Broker: c.Broker})")
+		}
+	}
+	g.P("reply := &", i.name, m.name, "Reply{}")
+	g.P("err := c.Client.Call(", strconv.Quote("Plugin."+m.name), ", args, reply)")
+	if streams {
+		g.P("wg.Wait()")
+	}
+	g.P("if err != nil {")
+	if m.hasErr {
+		g.P("return ", strings.Join(append(returns, "err"), ", "))
+	} else {
+		g.P("panic(err)")
+	}
+	g.P("}")
+	if m.hasErr {
+		g.P("if reply.Err != nil {")
+		g.P("return ", strings.Join(append(returns, "reply.Err"), ", "))
+		g.P("}")
+		returns = append(returns, "nil")
+	}
+	if len(returns) > 0 {
+		g.P("return ", strings.Join(returns, ", "))
+	}
+	g.P("}")
+	g.P()
+}
+
+func (g *generator) genServerMethod(i *iface, m *method) {
+	g.P("func (s *", i.name, "RPCServer) ", m.name, "(args *", i.name, m.name, "Args, reply *", i.name, m.name, "Reply) error {")
+	var callArgs []string
+	declaredErr := false
+	for n, p := range m.params {
+		field := fmt.Sprintf("args.A%d", n)
+		switch p.kind {
+		case kindValue:
+			if p.variadic {
+				field += "..."
+			}
+			callArgs = append(callArgs, field)
+		case kindReader, kindWriter:
+			declaredErr = true
+			g.P("a", n, ", err := s.Broker.Dial(", field, ")")
+			g.P("if err != nil {")
+			g.P("return err")
+			g.P("}")
+			g.P("defer a", n, ".Close()")
+			callArgs = append(callArgs, fmt.Sprintf("a%d", n))
+		case kindInterface:
+			declaredErr = true
+			g.P("conn", n, ", err := s.Broker.Dial(", field, ")")
+			g.P("if err != nil {")
+			g.P("return err")
+			g.P("}")
+			g.P("a", n, " := &", p.iface, "RPCClient{Client: rpc.NewClient(conn", n, "), Broker: s.Broker}")
+			g.P("defer a", n, ".Client.Close()")
+			callArgs = append(callArgs, fmt.Sprintf("a%d", n))
+		}
+	}
+
+	call := "s.Impl." + m.name + "(" + strings.Join(callArgs, ", ") + ")"
+	var fields []string
+	for n := range m.results {
+		fields = append(fields, fmt.Sprintf("reply.R%d", n))
+	}
+	switch {
+	case len(fields) == 0 && !m.hasErr:
+		g.P(call)
+	case len(fields) == 0:
+		g.P("reply.Err = plugin.NewBasicError(", call, ")")
+	case !m.hasErr:
+		g.P(strings.Join(fields, ", "), " = ", call)
+	default:
+		if !declaredErr {
+			g.P("var err error")
+		}
+		g.P(strings.Join(append(fields, "err"), ", "), " = ", call)
+		g.P("reply.Err = plugin.NewBasicError(err)")
+	}
+	g.P("return nil")
+	g.P("}")
+	g.P()
+}
+
+func (g *generator) genMessages(i *iface, m *method) {
+	g.P("// ", i.name, m.name, "Args are the arguments of ", i.name, ".", m.name, " over net/rpc.")
+	if len(m.params) == 0 {
+		g.P("type ", i.name, m.name, "Args struct{}")
+	} else {
+		g.P("type ", i.name, m.name, "Args struct {")
+	}
+	for n, p := range m.params {
+		switch {
+		case p.kind != kindValue:
+			g.P("A", n, " uint32 // broker ID")
+		case p.variadic:
+			g.P("A", n, " []", p.typ)
+		default:
+			g.P("A", n, " ", p.typ)
+		}
+	}
+	if len(m.params) > 0 {
+		g.P("}")
+	}
+	g.P()
+	g.P("// ", i.name, m.name, "Reply is the reply of ", i.name, ".", m.name, " over net/rpc.")
+	if len(m.results) == 0 && !m.hasErr {
+		g.P("type ", i.name, m.name, "Reply struct{}")
+		g.P()
+		return
+	}
+	g.P("type ", i.name, m.name, "Reply struct {")
+	for n, r := range m.results {
+		g.P("R", n, " ", r)
+	}
+	if m.hasErr {
+		g.P("Err *plugin.BasicError")
+	}
+	g.P("}")
+	g.P()
+}
@@ -0,0 +1,94 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the generated example")
+
+const exampleDir = "internal/example"
+
+// TestGenerate checks the generator output against the generated code in the
+// example package, which is compiled and tested as part of the module.
+func TestGenerate(t *testing.T) {
+	actual, err := generate(exampleDir, []string{"Store", "Observer"}, "store_netrpc.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The repository adds a license header to generated files.
+	const header = "// Copyright IBM Corp. 2016, 2026\n// SPDX-License-Identifier: MPL-2.0\n\n"
+	examplePath := filepath.Join(exampleDir, "store_netrpc.go")
+	if *update {
+		if err := os.WriteFile(examplePath, []byte(header+string(actual)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := os.ReadFile(examplePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(expected) != header+string(actual) {
+		t.Fatalf("generated code doesn't match %s, run go test -update:\n%s", examplePath, actual)
+	}
+}
+
+func TestGenerate_unsupported(t *testing.T) {
+	for name, tc := range map[string]struct {
+		src string
+		err string
+	}{
+		"not found": {
+			"type Other interface{}",
+			"interface Foo not found",
+		},
+		"not an interface": {
+			"type Foo struct{}",
+			"Foo is not an interface",
+		},
+		"embedded": {
+			"type Foo interface{ fmt.Stringer }",
+			"embedded interfaces are not supported",
+		},
+		"context": {
+			"type Foo interface{ Bar(ctx context.Context) }",
+			"Foo.Bar: context.Context is not supported",
+		},
+		"channel": {
+			"type Foo interface{ Bar(ch chan int) }",
+			"Foo.Bar: channels are not supported",
+		},
+		"unlisted interface": {
+			"type Foo interface{ Bar(Baz) }\ntype Baz interface{}",
+			"Foo.Bar: interface Baz must be listed with -type",
+		},
+		"reader result": {
+			"type Foo interface{ Bar() io.Reader }",
+			"Foo.Bar: io.Reader results are not supported",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := "package foo\n\nimport (\n\t\"context\"\n\t\"fmt\"\n\t\"io\"\n)\n\n" + tc.src + "\n"
+			if err := os.WriteFile(filepath.Join(dir, "foo.go"), []byte(src), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := generate(dir, []string{"Foo"}, "foo_netrpc.go")
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("bad: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package example is a package using go-plugin-netrpc. It is used to test
+// the generated code.
+package example
+
+import (
+	"io"
+	"time"
+)
+
+//go:generate go run github.com/hashicorp/go-plugin/cmd/go-plugin-netrpc -type Store,Observer
+
+// Store is a key/value store implemented by plugins.
+type Store interface {
+	Get(key string) ([]byte, error)
+	Put(key string, value []byte) error
+	Load(key string, r io.Reader) (int64, error)
+	Dump(key string, w io.Writer) error
+	Watch(o Observer) error
+	Keys(prefixes ...string) []string
+	Stats() (int, time.Time)
+	Reset()
+}
+
+// Observer is notified of changes by Store.Watch.
+type Observer interface {
+	Changed(key string, value []byte)
+}
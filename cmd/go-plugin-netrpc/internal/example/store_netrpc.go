@@ -0,0 +1,373 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Code generated by go-plugin-netrpc. DO NOT EDIT.
+
+package example
+
+import (
+	"io"
+	"net/rpc"
+	"sync"
+	"time"
+
+	plugin "github.com/hashicorp/go-plugin"
+)
+
+// StorePlugin is the plugin.Plugin serving and dispensing Store over net/rpc.
+type StorePlugin struct {
+	// Impl is the Store served by the plugin.
+	Impl Store
+}
+
+var _ plugin.Plugin = (*StorePlugin)(nil)
+
+func (p *StorePlugin) Server(b *plugin.MuxBroker) (interface{}, error) {
+	return &StoreRPCServer{Impl: p.Impl, Broker: b}, nil
+}
+
+func (p *StorePlugin) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
+	return &StoreRPCClient{Client: c, Broker: b}, nil
+}
+
+// StoreRPCClient is an implementation of Store that talks over RPC.
+type StoreRPCClient struct {
+	Client *rpc.Client
+	Broker *plugin.MuxBroker
+}
+
+var _ Store = (*StoreRPCClient)(nil)
+
+func (c *StoreRPCClient) Get(a0 string) ([]byte, error) {
+	args := &StoreGetArgs{}
+	args.A0 = a0
+	reply := &StoreGetReply{}
+	err := c.Client.Call("Plugin.Get", args, reply)
+	if err != nil {
+		return reply.R0, err
+	}
+	if reply.Err != nil {
+		return reply.R0, reply.Err
+	}
+	return reply.R0, nil
+}
+
+func (c *StoreRPCClient) Put(a0 string, a1 []byte) error {
+	args := &StorePutArgs{}
+	args.A0 = a0
+	args.A1 = a1
+	reply := &StorePutReply{}
+	err := c.Client.Call("Plugin.Put", args, reply)
+	if err != nil {
+		return err
+	}
+	if reply.Err != nil {
+		return reply.Err
+	}
+	return nil
+}
+
+func (c *StoreRPCClient) Load(a0 string, a1 io.Reader) (int64, error) {
+	args := &StoreLoadArgs{}
+	var wg sync.WaitGroup
+	args.A0 = a0
+	args.A1 = c.Broker.NextId()
+	wg.Add(1)
+	go func(id uint32) {
+		defer wg.Done()
+		conn, err := c.Broker.Accept(id)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if a1 != nil {
+			_, _ = io.Copy(conn, a1)
+		}
+	}(args.A1)
+	reply := &StoreLoadReply{}
+	err := c.Client.Call("Plugin.Load", args, reply)
+	wg.Wait()
+	if err != nil {
+		return reply.R0, err
+	}
+	if reply.Err != nil {
+		return reply.R0, reply.Err
+	}
+	return reply.R0, nil
+}
+
+func (c *StoreRPCClient) Dump(a0 string, a1 io.Writer) error {
+	args := &StoreDumpArgs{}
+	var wg sync.WaitGroup
+	args.A0 = a0
+	args.A1 = c.Broker.NextId()
+	wg.Add(1)
+	go func(id uint32) {
+		defer wg.Done()
+		conn, err := c.Broker.Accept(id)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if a1 != nil {
+			_, _ = io.Copy(a1, conn)
+		}
+	}(args.A1)
+	reply := &StoreDumpReply{}
+	err := c.Client.Call("Plugin.Dump", args, reply)
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	if reply.Err != nil {
+		return reply.Err
+	}
+	return nil
+}
+
+func (c *StoreRPCClient) Watch(a0 Observer) error {
+	args := &StoreWatchArgs{}
+	args.A0 = c.Broker.NextId()
+	go c.Broker.AcceptAndServe(args.A0, &ObserverRPCServer{Impl: a0, Broker: c.Broker})
+	reply := &StoreWatchReply{}
+	err := c.Client.Call("Plugin.Watch", args, reply)
+	if err != nil {
+		return err
+	}
+	if reply.Err != nil {
+		return reply.Err
+	}
+	return nil
+}
+
+func (c *StoreRPCClient) Keys(a0 ...string) []string {
+	args := &StoreKeysArgs{}
+	args.A0 = a0
+	reply := &StoreKeysReply{}
+	err := c.Client.Call("Plugin.Keys", args, reply)
+	if err != nil {
+		panic(err)
+	}
+	return reply.R0
+}
+
+func (c *StoreRPCClient) Stats() (int, time.Time) {
+	args := &StoreStatsArgs{}
+	reply := &StoreStatsReply{}
+	err := c.Client.Call("Plugin.Stats", args, reply)
+	if err != nil {
+		panic(err)
+	}
+	return reply.R0, reply.R1
+}
+
+func (c *StoreRPCClient) Reset() {
+	args := &StoreResetArgs{}
+	reply := &StoreResetReply{}
+	err := c.Client.Call("Plugin.Reset", args, reply)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// StoreRPCServer is the RPC server that StoreRPCClient talks to, conforming to
+// the requirements of net/rpc.
+type StoreRPCServer struct {
+	Impl   Store
+	Broker *plugin.MuxBroker
+}
+
+func (s *StoreRPCServer) Get(args *StoreGetArgs, reply *StoreGetReply) error {
+	var err error
+	reply.R0, err = s.Impl.Get(args.A0)
+	reply.Err = plugin.NewBasicError(err)
+	return nil
+}
+
+func (s *StoreRPCServer) Put(args *StorePutArgs, reply *StorePutReply) error {
+	reply.Err = plugin.NewBasicError(s.Impl.Put(args.A0, args.A1))
+	return nil
+}
+
+func (s *StoreRPCServer) Load(args *StoreLoadArgs, reply *StoreLoadReply) error {
+	a1, err := s.Broker.Dial(args.A1)
+	if err != nil {
+		return err
+	}
+	defer a1.Close()
+	reply.R0, err = s.Impl.Load(args.A0, a1)
+	reply.Err = plugin.NewBasicError(err)
+	return nil
+}
+
+func (s *StoreRPCServer) Dump(args *StoreDumpArgs, reply *StoreDumpReply) error {
+	a1, err := s.Broker.Dial(args.A1)
+	if err != nil {
+		return err
+	}
+	defer a1.Close()
+	reply.Err = plugin.NewBasicError(s.Impl.Dump(args.A0, a1))
+	return nil
+}
+
+func (s *StoreRPCServer) Watch(args *StoreWatchArgs, reply *StoreWatchReply) error {
+	conn0, err := s.Broker.Dial(args.A0)
+	if err != nil {
+		return err
+	}
+	a0 := &ObserverRPCClient{Client: rpc.NewClient(conn0), Broker: s.Broker}
+	defer a0.Client.Close()
+	reply.Err = plugin.NewBasicError(s.Impl.Watch(a0))
+	return nil
+}
+
+func (s *StoreRPCServer) Keys(args *StoreKeysArgs, reply *StoreKeysReply) error {
+	reply.R0 = s.Impl.Keys(args.A0...)
+	return nil
+}
+
+func (s *StoreRPCServer) Stats(args *StoreStatsArgs, reply *StoreStatsReply) error {
+	reply.R0, reply.R1 = s.Impl.Stats()
+	return nil
+}
+
+func (s *StoreRPCServer) Reset(args *StoreResetArgs, reply *StoreResetReply) error {
+	s.Impl.Reset()
+	return nil
+}
+
+// StoreGetArgs are the arguments of Store.Get over net/rpc.
+type StoreGetArgs struct {
+	A0 string
+}
+
+// StoreGetReply is the reply of Store.Get over net/rpc.
+type StoreGetReply struct {
+	R0  []byte
+	Err *plugin.BasicError
+}
+
+// StorePutArgs are the arguments of Store.Put over net/rpc.
+type StorePutArgs struct {
+	A0 string
+	A1 []byte
+}
+
+// StorePutReply is the reply of Store.Put over net/rpc.
+type StorePutReply struct {
+	Err *plugin.BasicError
+}
+
+// StoreLoadArgs are the arguments of Store.Load over net/rpc.
+type StoreLoadArgs struct {
+	A0 string
+	A1 uint32 // broker ID
+}
+
+// StoreLoadReply is the reply of Store.Load over net/rpc.
+type StoreLoadReply struct {
+	R0  int64
+	Err *plugin.BasicError
+}
+
+// StoreDumpArgs are the arguments of Store.Dump over net/rpc.
+type StoreDumpArgs struct {
+	A0 string
+	A1 uint32 // broker ID
+}
+
+// StoreDumpReply is the reply of Store.Dump over net/rpc.
+type StoreDumpReply struct {
+	Err *plugin.BasicError
+}
+
+// StoreWatchArgs are the arguments of Store.Watch over net/rpc.
+type StoreWatchArgs struct {
+	A0 uint32 // broker ID
+}
+
+// StoreWatchReply is the reply of Store.Watch over net/rpc.
+type StoreWatchReply struct {
+	Err *plugin.BasicError
+}
+
+// StoreKeysArgs are the arguments of Store.Keys over net/rpc.
+type StoreKeysArgs struct {
+	A0 []string
+}
+
+// StoreKeysReply is the reply of Store.Keys over net/rpc.
+type StoreKeysReply struct {
+	R0 []string
+}
+
+// StoreStatsArgs are the arguments of Store.Stats over net/rpc.
+type StoreStatsArgs struct{}
+
+// StoreStatsReply is the reply of Store.Stats over net/rpc.
+type StoreStatsReply struct {
+	R0 int
+	R1 time.Time
+}
+
+// StoreResetArgs are the arguments of Store.Reset over net/rpc.
+type StoreResetArgs struct{}
+
+// StoreResetReply is the reply of Store.Reset over net/rpc.
+type StoreResetReply struct{}
+
+// ObserverPlugin is the plugin.Plugin serving and dispensing Observer over net/rpc.
+type ObserverPlugin struct {
+	// Impl is the Observer served by the plugin.
+	Impl Observer
+}
+
+var _ plugin.Plugin = (*ObserverPlugin)(nil)
+
+func (p *ObserverPlugin) Server(b *plugin.MuxBroker) (interface{}, error) {
+	return &ObserverRPCServer{Impl: p.Impl, Broker: b}, nil
+}
+
+func (p *ObserverPlugin) Client(b *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
+	return &ObserverRPCClient{Client: c, Broker: b}, nil
+}
+
+// ObserverRPCClient is an implementation of Observer that talks over RPC.
+type ObserverRPCClient struct {
+	Client *rpc.Client
+	Broker *plugin.MuxBroker
+}
+
+var _ Observer = (*ObserverRPCClient)(nil)
+
+func (c *ObserverRPCClient) Changed(a0 string, a1 []byte) {
+	args := &ObserverChangedArgs{}
+	args.A0 = a0
+	args.A1 = a1
+	reply := &ObserverChangedReply{}
+	err := c.Client.Call("Plugin.Changed", args, reply)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ObserverRPCServer is the RPC server that ObserverRPCClient talks to, conforming to
+// the requirements of net/rpc.
+type ObserverRPCServer struct {
+	Impl   Observer
+	Broker *plugin.MuxBroker
+}
+
+func (s *ObserverRPCServer) Changed(args *ObserverChangedArgs, reply *ObserverChangedReply) error {
+	s.Impl.Changed(args.A0, args.A1)
+	return nil
+}
+
+// ObserverChangedArgs are the arguments of Observer.Changed over net/rpc.
+type ObserverChangedArgs struct {
+	A0 string
+	A1 []byte
+}
+
+// ObserverChangedReply is the reply of Observer.Changed over net/rpc.
+type ObserverChangedReply struct{}
@@ -0,0 +1,184 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package example
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	plugin "github.com/hashicorp/go-plugin"
+)
+
+// memStore is an in-memory Store.
+type memStore struct {
+	sync.Mutex
+	data map[string][]byte
+	at   time.Time
+}
+
+func (s *memStore) Get(key string) ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+	v, ok := s.data[key]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found", key)
+	}
+	return v, nil
+}
+
+func (s *memStore) Put(key string, value []byte) error {
+	s.Lock()
+	defer s.Unlock()
+	s.data[key] = value
+	return nil
+}
+
+func (s *memStore) Load(key string, r io.Reader) (int64, error) {
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, r)
+	if err != nil {
+		return n, err
+	}
+	return n, s.Put(key, buf.Bytes())
+}
+
+func (s *memStore) Dump(key string, w io.Writer) error {
+	v, err := s.Get(key)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(v)
+	return err
+}
+
+func (s *memStore) Watch(o Observer) error {
+	for _, k := range s.Keys() {
+		v, err := s.Get(k)
+		if err != nil {
+			return err
+		}
+		o.Changed(k, v)
+	}
+	return nil
+}
+
+func (s *memStore) Keys(prefixes ...string) []string {
+	s.Lock()
+	defer s.Unlock()
+	var keys []string
+	for k := range s.data {
+		match := len(prefixes) == 0
+		for _, p := range prefixes {
+			match = match || strings.HasPrefix(k, p)
+		}
+		if match {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *memStore) Stats() (int, time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.data), s.at
+}
+
+func (s *memStore) Reset() {
+	s.Lock()
+	defer s.Unlock()
+	s.data = make(map[string][]byte)
+}
+
+// recorder is an Observer recording the changes.
+type recorder struct {
+	changes []string
+}
+
+func (r *recorder) Changed(key string, value []byte) {
+	r.changes = append(r.changes, key+"="+string(value))
+}
+
+func TestStorePlugin(t *testing.T) {
+	at := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	impl := &memStore{data: make(map[string][]byte), at: at}
+	client, _ := plugin.TestPluginRPCConn(t, map[string]plugin.Plugin{
+		"store": &StorePlugin{Impl: impl},
+	}, nil)
+	defer client.Close()
+
+	raw, err := client.Dispense("store")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	store := raw.(Store)
+
+	if err := store.Put("a", []byte("1")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	v, err := store.Get("a")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(v) != "1" {
+		t.Fatalf("bad: %q", v)
+	}
+
+	// Errors are passed back as BasicErrors
+	_, err = store.Get("missing")
+	if _, ok := err.(*plugin.BasicError); !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+	if err.Error() != `key "missing" not found` {
+		t.Fatalf("bad: %s", err)
+	}
+
+	// io.Reader arguments are streamed to the plugin
+	n, err := store.Load("b", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n != 5 {
+		t.Fatalf("bad: %d", n)
+	}
+
+	// io.Writer arguments are streamed from the plugin
+	var buf bytes.Buffer
+	if err := store.Dump("b", &buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if buf.String() != "hello" {
+		t.Fatalf("bad: %q", buf.String())
+	}
+
+	// Interface arguments are served to the plugin
+	rec := &recorder{}
+	if err := store.Watch(rec); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if expected := []string{"a=1", "b=hello"}; !reflect.DeepEqual(rec.changes, expected) {
+		t.Fatalf("bad: %#v", rec.changes)
+	}
+
+	if keys := store.Keys("a", "c"); !reflect.DeepEqual(keys, []string{"a"}) {
+		t.Fatalf("bad: %#v", keys)
+	}
+	count, statAt := store.Stats()
+	if count != 2 || !statAt.Equal(at) {
+		t.Fatalf("bad: %d %s", count, statAt)
+	}
+
+	store.Reset()
+	if count, _ := store.Stats(); count != 0 {
+		t.Fatalf("bad: %d", count)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// go-plugin-netrpc generates net/rpc glue for Go interfaces, so they can be
+// served and dispensed by plugins without writing it by hand. It is meant
+// to be run by go generate from the package declaring the interfaces:
+//
+//	//go:generate go run github.com/hashicorp/go-plugin/cmd/go-plugin-netrpc -type Greeter
+//
+// For each interface Foo listed with -type, it generates:
+//
+//   - FooRPCClient, the Foo implementation calling the plugin over net/rpc.
+//   - FooRPCServer, the net/rpc server calling the plugin's Foo.
+//   - FooPlugin, the plugin.Plugin implementation serving and dispensing Foo.
+//   - FooBarArgs and FooBarReply, the net/rpc messages of each method Bar.
+//
+// Methods returning an error as their last result pass it back to the host
+// as a *plugin.BasicError, in which errors.Is and errors.As find the errors
+// registered with plugin.RegisterSentinelError and plugin.RegisterErrorType.
+// Other errors, such as a lost connection, are returned by methods returning
+// an error and cause a panic otherwise.
+//
+// Arguments of type io.Reader and io.Writer are streamed over a connection
+// of the MuxBroker for the duration of the call. Arguments whose type is
+// another interface listed with -type are served over the MuxBroker too, so
+// the plugin can call back into the host while the call is running.
+//
+// Other arguments and results are encoded with encoding/gob, so they must
+// be types gob can encode. Channels, functions and other interfaces are
+// not supported.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of interface names; required")
+	output := flag.String("output", "", "output file name; default <type>_netrpc.go")
+	flag.Parse()
+
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	types := strings.Split(*typeNames, ",")
+
+	dir := "."
+	if args := flag.Args(); len(args) > 0 {
+		dir = args[0]
+	}
+	if *output == "" {
+		*output = strings.ToLower(types[0]) + "_netrpc.go"
+	}
+	if !filepath.IsAbs(*output) {
+		*output = filepath.Join(dir, *output)
+	}
+
+	src, err := generate(dir, types, filepath.Base(*output))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "go-plugin-netrpc: %s\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*output, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "go-plugin-netrpc: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	pluginPackage  = protogen.GoImportPath("github.com/hashicorp/go-plugin")
+	grpcPackage    = protogen.GoImportPath("google.golang.org/grpc")
+	contextPackage = protogen.GoImportPath("context")
+	errorsPackage  = protogen.GoImportPath("errors")
+	fmtPackage     = protogen.GoImportPath("fmt")
+	ioPackage      = protogen.GoImportPath("io")
+	syncPackage    = protogen.GoImportPath("sync")
+)
+
+// callbackRe matches the comment marking a field as a callback.
+var callbackRe = regexp.MustCompile(`plugin:callback=(\w+)`)
+
+// config holds the generator's parameters.
+type config struct {
+	protocolVersion  uint
+	magicCookieKey   string
+	magicCookieValue string
+}
+
+// callbackField is a field holding the broker ID of a callback service.
+type callbackField struct {
+	message *protogen.Message
+	field   *protogen.Field
+	service *protogen.Service
+}
+
+func generate(gen *protogen.Plugin, cfg *config) error {
+	for _, f := range gen.Files {
+		if !f.Generate || len(f.Services) == 0 {
+			continue
+		}
+		if err := generateFile(gen, f, cfg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func generateFile(gen *protogen.Plugin, f *protogen.File, cfg *config) error {
+	services := make(map[string]*protogen.Service)
+	for _, s := range f.Services {
+		services[s.GoName] = s
+	}
+
+	callbacks, err := callbackFields(f.Messages, services)
+	if err != nil {
+		return err
+	}
+	isCallback := make(map[*protogen.Service]bool)
+	for _, cb := range callbacks {
+		isCallback[cb.service] = true
+	}
+
+	g := gen.NewGeneratedFile(f.GeneratedFilenamePrefix+"_plugin.pb.go", f.GoImportPath)
+	g.P("// Code generated by protoc-gen-go-plugin. DO NOT EDIT.")
+	g.P("// source: ", f.Desc.Path())
+	g.P()
+	g.P("package ", f.GoPackageName)
+	g.P()
+
+	for _, s := range f.Services {
+		if isCallback[s] {
+			generateCallbackService(g, f, s)
+		} else {
+			generatePluginService(g, f, s, cfg)
+		}
+	}
+	for _, cb := range callbacks {
+		generateCallbackField(g, f, cb)
+	}
+
+	return nil
+}
+
+// callbackFields finds the fields marked as callbacks in messages and their
+// nested messages.
+func callbackFields(messages []*protogen.Message, services map[string]*protogen.Service) ([]callbackField, error) {
+	var result []callbackField
+	for _, m := range messages {
+		for _, field := range m.Fields {
+			match := callbackRe.FindStringSubmatch(string(field.Comments.Leading) + string(field.Comments.Trailing))
+			if match == nil {
+				continue
+			}
+
+			if field.Desc.Kind() != protoreflect.Uint32Kind || field.Desc.IsList() {
+				return nil, fmt.Errorf("%s: callback fields must be uint32", field.Desc.FullName())
+			}
+			s, ok := services[match[1]]
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown callback service %q, it must be in the same file", field.Desc.FullName(), match[1])
+			}
+
+			result = append(result, callbackField{message: m, field: field, service: s})
+		}
+
+		nested, err := callbackFields(m.Messages, services)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, nested...)
+	}
+
+	return result, nil
+}
+
+func generatePluginService(g *protogen.GeneratedFile, f *protogen.File, s *protogen.Service, cfg *config) {
+	name := s.GoName
+	server := f.GoImportPath.Ident(name + "Server")
+	client := f.GoImportPath.Ident(name + "Client")
+	broker := pluginPackage.Ident("GRPCBroker")
+
+	key, value := cfg.magicCookieKey, cfg.magicCookieValue
+	if key == "" {
+		key = screamingSnake(name) + "_PLUGIN"
+	}
+	if value == "" {
+		sum := sha256.Sum256([]byte(s.Desc.FullName()))
+		value = hex.EncodeToString(sum[:16])
+	}
+
+	g.P("// ", name, "PluginName is the name ", name, " plugins are served and dispensed")
+	g.P("// with.")
+	g.P("const ", name, "PluginName = ", fmt.Sprintf("%q", strings.ToLower(name)))
+	g.P()
+	g.P("// ", name, "Handshake is the handshake configuration shared by hosts and ", name)
+	g.P("// plugins.")
+	g.P("var ", name, "Handshake = ", pluginPackage.Ident("HandshakeConfig"), "{")
+	g.P("ProtocolVersion: ", cfg.protocolVersion, ",")
+	g.P("MagicCookieKey: ", fmt.Sprintf("%q", key), ",")
+	g.P("MagicCookieValue: ", fmt.Sprintf("%q", value), ",")
+	g.P("}")
+	g.P()
+
+	g.P("// ", name, "Plugin is the plugin.GRPCPlugin for the ", name, " service.")
+	g.P("type ", name, "Plugin struct {")
+	g.P(pluginPackage.Ident("NetRPCUnsupportedPlugin"))
+	g.P()
+	g.P("// Impl is the implementation served by the plugin.")
+	g.P("Impl ", server)
+	g.P()
+	g.P("// ImplFunc, if set, is used instead of Impl to create the")
+	g.P("// implementation with access to the broker, for example to dial")
+	g.P("// callbacks.")
+	g.P("ImplFunc func(*", broker, ") ", server)
+	g.P("}")
+	g.P()
+	g.P("func (p *", name, "Plugin) GRPCServer(broker *", broker, ", s *", grpcPackage.Ident("Server"), ") error {")
+	g.P("impl := p.Impl")
+	g.P("if p.ImplFunc != nil {")
+	g.P("impl = p.ImplFunc(broker)")
+	g.P("}")
+	g.P("if impl == nil {")
+	g.P("return ", errorsPackage.Ident("New"), "(", fmt.Sprintf("%q", "no "+name+" implementation"), ")")
+	g.P("}")
+	g.P()
+	g.P(f.GoImportPath.Ident("Register"+name+"Server"), "(s, impl)")
+	g.P("return nil")
+	g.P("}")
+	g.P()
+	g.P("func (p *", name, "Plugin) GRPCClient(ctx ", contextPackage.Ident("Context"), ", broker *", broker, ", c *", grpcPackage.Ident("ClientConn"), ") (interface{}, error) {")
+	g.P("return &", name, "PluginClient{")
+	g.P(name, "Client: ", f.GoImportPath.Ident("New"+name+"Client"), "(c),")
+	g.P("Broker: broker,")
+	g.P("}, nil")
+	g.P("}")
+	g.P()
+
+	g.P("// ", name, "PluginClient is the host's client for a ", name, " plugin.")
+	g.P("type ", name, "PluginClient struct {")
+	g.P(client)
+	g.P()
+	g.P("// Broker is used to serve callbacks to the plugin.")
+	g.P("Broker *", broker)
+	g.P("}")
+	g.P()
+
+	g.P("// ", name, "PluginMap returns the plugins hosts should set in")
+	g.P("// plugin.ClientConfig to use ", name, " plugins.")
+	g.P("func ", name, "PluginMap() map[string]", pluginPackage.Ident("Plugin"), " {")
+	g.P("return map[string]", pluginPackage.Ident("Plugin"), "{")
+	g.P(name, "PluginName: &", name, "Plugin{},")
+	g.P("}")
+	g.P("}")
+	g.P()
+
+	g.P("// Serve", name, " serves p as a ", name, " plugin. It is meant to be called from")
+	g.P("// the plugin's main function.")
+	g.P("func Serve", name, "(p *", name, "Plugin) {")
+	g.P(pluginPackage.Ident("Serve"), "(&", pluginPackage.Ident("ServeConfig"), "{")
+	g.P("HandshakeConfig: ", name, "Handshake,")
+	g.P("Plugins: ", pluginPackage.Ident("PluginSet"), "{", name, "PluginName: p},")
+	g.P("GRPCServer: ", pluginPackage.Ident("DefaultGRPCServer"), ",")
+	g.P("})")
+	g.P("}")
+	g.P()
+
+	g.P("// Dispense", name, " dispenses the ", name, " plugin from a client using")
+	g.P("// ", name, "PluginMap.")
+	g.P("func Dispense", name, "(c ", pluginPackage.Ident("ClientProtocol"), ") (*", name, "PluginClient, error) {")
+	g.P("raw, err := c.Dispense(", name, "PluginName)")
+	g.P("if err != nil {")
+	g.P("return nil, err")
+	g.P("}")
+	g.P()
+	g.P("client, ok := raw.(*", name, "PluginClient)")
+	g.P("if !ok {")
+	g.P("return nil, ", fmtPackage.Ident("Errorf"), "(", fmt.Sprintf("%q", "plugin %q is %T, not *"+name+"PluginClient"), ", ", name, "PluginName, raw)")
+	g.P("}")
+	g.P()
+	g.P("return client, nil")
+	g.P("}")
+	g.P()
+}
+
+func generateCallbackService(g *protogen.GeneratedFile, f *protogen.File, s *protogen.Service) {
+	name := s.GoName
+	broker := pluginPackage.Ident("GRPCBroker")
+	grpcServer := grpcPackage.Ident("Server")
+
+	g.P("// Serve", name, "Callback serves impl to the other side of broker. The returned")
+	g.P("// broker ID should be sent to it, and stop called once the callback is no")
+	g.P("// longer needed.")
+	g.P("func Serve", name, "Callback(broker *", broker, ", impl ", f.GoImportPath.Ident(name+"Server"), ") (id uint32, stop func()) {")
+	g.P("var lock ", syncPackage.Ident("Mutex"))
+	g.P("var server *", grpcServer)
+	g.P("var stopped bool")
+	g.P()
+	g.P("id = broker.NextId()")
+	g.P("go broker.AcceptAndServe(id, func(opts []", grpcPackage.Ident("ServerOption"), ") *", grpcServer, " {")
+	g.P("s := ", grpcPackage.Ident("NewServer"), "(opts...)")
+	g.P(f.GoImportPath.Ident("Register"+name+"Server"), "(s, impl)")
+	g.P()
+	g.P("lock.Lock()")
+	g.P("defer lock.Unlock()")
+	g.P("if stopped {")
+	g.P("s.Stop()")
+	g.P("}")
+	g.P("server = s")
+	g.P("return s")
+	g.P("})")
+	g.P()
+	g.P("return id, func() {")
+	g.P("lock.Lock()")
+	g.P("defer lock.Unlock()")
+	g.P("stopped = true")
+	g.P("if server != nil {")
+	g.P("server.Stop()")
+	g.P("}")
+	g.P("}")
+	g.P("}")
+	g.P()
+
+	g.P("// Dial", name, "Callback connects to the ", name, " served by the other side of")
+	g.P("// broker with the given ID. The returned closer should be closed once done.")
+	g.P("func Dial", name, "Callback(broker *", broker, ", id uint32) (", f.GoImportPath.Ident(name+"Client"), ", ", ioPackage.Ident("Closer"), ", error) {")
+	g.P("conn, err := broker.Dial(id)")
+	g.P("if err != nil {")
+	g.P("return nil, nil, err")
+	g.P("}")
+	g.P()
+	g.P("return ", f.GoImportPath.Ident("New"+name+"Client"), "(conn), conn, nil")
+	g.P("}")
+	g.P()
+}
+
+func generateCallbackField(g *protogen.GeneratedFile, f *protogen.File, cb callbackField) {
+	msg := cb.message.GoIdent.GoName
+	field := cb.field.GoName
+	svc := cb.service.GoName
+	broker := pluginPackage.Ident("GRPCBroker")
+
+	g.P("// Serve", field, " serves impl as the ", field, " callback, setting ", field, " to")
+	g.P("// its broker ID. stop should be called once the request has completed.")
+	g.P("func (x *", msg, ") Serve", field, "(broker *", broker, ", impl ", f.GoImportPath.Ident(svc+"Server"), ") (stop func()) {")
+	g.P("x.", field, ", stop = Serve", svc, "Callback(broker, impl)")
+	g.P("return stop")
+	g.P("}")
+	g.P()
+
+	g.P("// Dial", field, " connects to the ", svc, " callback given by ", field, ".")
+	g.P("func (x *", msg, ") Dial", field, "(broker *", broker, ") (", f.GoImportPath.Ident(svc+"Client"), ", ", ioPackage.Ident("Closer"), ", error) {")
+	g.P("return Dial", svc, "Callback(broker, x.Get", field, "())")
+	g.P("}")
+	g.P()
+}
+
+// screamingSnake converts a Go name such as KeyValue to KEY_VALUE.
+func screamingSnake(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(rune(name[i-1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,130 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	kvproto "github.com/hashicorp/go-plugin/examples/bidirectional/proto"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+var update = flag.Bool("update", false, "update the generated example")
+
+const examplePath = "../../examples/bidirectional/proto/kv_plugin.pb.go"
+
+// kvFile returns the descriptor of the bidirectional example's kv.proto.
+// Compiled descriptors don't include comments, so the callback comment on
+// PutRequest.add_server is added back.
+func kvFile() *descriptorpb.FileDescriptorProto {
+	fd := protodesc.ToFileDescriptorProto(kvproto.File_proto_kv_proto)
+	fd.SourceCodeInfo = &descriptorpb.SourceCodeInfo{
+		Location: []*descriptorpb.SourceCodeInfo_Location{{
+			// message_type[2] (PutRequest), field[0] (add_server)
+			Path:             []int32{4, 2, 2, 0},
+			Span:             []int32{16, 4, 27},
+			TrailingComments: proto.String(" plugin:callback=AddHelper\n"),
+		}},
+	}
+	return fd
+}
+
+func run(t *testing.T, fd *descriptorpb.FileDescriptorProto, param string) (map[string]string, error) {
+	t.Helper()
+
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{fd.GetName()},
+		Parameter:      proto.String(param),
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{fd},
+	}
+	cfg := &config{}
+	gen, err := options(cfg).New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := generate(gen, cfg); err != nil {
+		return nil, err
+	}
+
+	resp := gen.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	files := make(map[string]string)
+	for _, f := range resp.File {
+		files[f.GetName()] = f.GetContent()
+	}
+	return files, nil
+}
+
+// TestGenerate checks the generator output against the generated code in the
+// bidirectional example, which is compiled as part of the module.
+func TestGenerate(t *testing.T) {
+	files, err := run(t, kvFile(), "paths=source_relative")
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, ok := files["proto/kv_plugin.pb.go"]
+	if !ok {
+		t.Fatalf("kv_plugin.pb.go not generated, got %v", files)
+	}
+
+	// The repository adds a license header to generated files.
+	const header = "// Copyright IBM Corp. 2016, 2026\n// SPDX-License-Identifier: MPL-2.0\n\n"
+	if *update {
+		if err := os.WriteFile(examplePath, []byte(header+actual), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := os.ReadFile(examplePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(expected) != header+actual {
+		t.Fatalf("generated code doesn't match %s, run go test -update:\n%s", examplePath, actual)
+	}
+}
+
+func TestGenerate_handshakeParams(t *testing.T) {
+	files, err := run(t, kvFile(), "paths=source_relative,protocol_version=3,magic_cookie_key=KV,magic_cookie_value=abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := files["proto/kv_plugin.pb.go"]
+	for _, s := range []string{"ProtocolVersion:  3,", `MagicCookieKey:   "KV",`, `MagicCookieValue: "abc",`} {
+		if !strings.Contains(actual, s) {
+			t.Fatalf("expected %q in:\n%s", s, actual)
+		}
+	}
+}
+
+func TestGenerate_invalidCallback(t *testing.T) {
+	for name, tc := range map[string]struct {
+		path    []int32
+		comment string
+	}{
+		// PutRequest.key
+		"not uint32": {[]int32{4, 2, 2, 1}, " plugin:callback=AddHelper\n"},
+		// PutRequest.add_server
+		"unknown service": {[]int32{4, 2, 2, 0}, " plugin:callback=Missing\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fd := kvFile()
+			fd.SourceCodeInfo.Location[0].Path = tc.path
+			fd.SourceCodeInfo.Location[0].TrailingComments = proto.String(tc.comment)
+
+			if _, err := run(t, fd, "paths=source_relative"); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
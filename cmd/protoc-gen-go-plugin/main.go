@@ -0,0 +1,57 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// protoc-gen-go-plugin generates go-plugin glue for gRPC services. It is
+// used alongside protoc-gen-go and protoc-gen-go-grpc, and its output must
+// be in the same Go package as theirs.
+//
+// For each service Foo in a file, it generates:
+//
+//   - FooPlugin, the plugin.GRPCPlugin implementation serving a FooServer.
+//   - FooPluginClient, the dispensed FooClient along with the GRPCBroker.
+//   - FooHandshake and FooPluginName, shared by hosts and plugins.
+//   - FooPluginMap for hosts, ServeFoo for plugins, and DispenseFoo.
+//
+// Services can also be passed as callbacks over the GRPCBroker. A uint32
+// field holding a broker ID is marked with a comment such as:
+//
+//	uint32 add_server = 1; // plugin:callback=AddHelper
+//
+// For such a field AddServer, the message gets ServeAddServer and
+// DialAddServer methods that serve and connect to the callback. Services
+// only used as callbacks don't get the plugin glue. For every callback
+// service Bar, ServeBarCallback and DialBarCallback are generated too.
+//
+// The handshake can be set with the protocol_version, magic_cookie_key
+// and magic_cookie_value parameters. By default the protocol version is 1,
+// the key is derived from the service name, such as FOO_PLUGIN, and the
+// value from a hash of the service's full name.
+package main
+
+import (
+	"flag"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+func main() {
+	cfg := &config{}
+	options(cfg).Run(func(gen *protogen.Plugin) error {
+		gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+		return generate(gen, cfg)
+	})
+}
+
+// options returns the protogen options parsing the generator's parameters
+// into cfg.
+func options(cfg *config) protogen.Options {
+	var flags flag.FlagSet
+	flags.UintVar(&cfg.protocolVersion, "protocol_version", 1, "plugin protocol version")
+	flags.StringVar(&cfg.magicCookieKey, "magic_cookie_key", "", "handshake magic cookie key")
+	flags.StringVar(&cfg.magicCookieValue, "magic_cookie_value", "", "handshake magic cookie value")
+
+	return protogen.Options{
+		ParamFunc: flags.Set,
+	}
+}
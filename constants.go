@@ -12,5 +12,23 @@ const (
 	// sockets created by _plugins_. Does not affect client behavior.
 	EnvUnixSocketGroup = "PLUGIN_UNIX_SOCKET_GROUP"
 
+	// EnvReattachPlugins holds a JSON object mapping plugin names to
+	// ReattachConfigs. Clients with ClientConfig.ReattachName set reattach to
+	// the matching plugin instead of launching it. See ReattachConfigsFromEnv.
+	EnvReattachPlugins = "PLUGIN_REATTACH"
+
 	envMultiplexGRPC = "PLUGIN_MULTIPLEX_GRPC"
+
+	// envMultiplexGRPCStreamIDs is set by hosts able to start multiplexed
+	// gRPC broker connections with their stream ID.
+	envMultiplexGRPCStreamIDs = "PLUGIN_MULTIPLEX_GRPC_STREAM_IDS"
+
+	// envPluginProtocols is the host's AllowedProtocols, most preferred
+	// first.
+	envPluginProtocols = "PLUGIN_PROTOCOLS"
+
+	// Set by a service manager implementing systemd-style socket activation.
+	envListenPid     = "LISTEN_PID"
+	envListenFds     = "LISTEN_FDS"
+	envListenFdNames = "LISTEN_FDNAMES"
 )
@@ -0,0 +1,148 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Dispense dispenses the plugin with the given name from c and checks that
+// it implements T. This avoids unchecked type assertions on the result of
+// ClientProtocol.Dispense:
+//
+//	kv, err := plugin.Dispense[shared.KV](rpcClient, "kv")
+//
+// If the dispensed value isn't a T, the error is a *DispenseTypeError.
+func Dispense[T any](c ClientProtocol, name string) (T, error) {
+	var zero T
+	raw, err := c.Dispense(name)
+	if err != nil {
+		return zero, err
+	}
+
+	v, ok := raw.(T)
+	if !ok {
+		return zero, &DispenseTypeError{
+			Name:     name,
+			Expected: reflect.TypeOf((*T)(nil)).Elem(),
+			Actual:   reflect.TypeOf(raw),
+		}
+	}
+	return v, nil
+}
+
+// DispenseTypeError is returned by Dispense when the dispensed plugin isn't
+// of the expected type.
+type DispenseTypeError struct {
+	// Name is the name of the dispensed plugin.
+	Name string
+
+	// Expected is the type requested from Dispense and Actual the type of
+	// the value dispensed by the plugin. Actual is nil if the plugin
+	// dispensed nil.
+	Expected reflect.Type
+	Actual   reflect.Type
+}
+
+func (e *DispenseTypeError) Error() string {
+	actual := "nil"
+	if e.Actual != nil {
+		actual = e.Actual.String()
+	}
+
+	verb := "is not"
+	if e.Expected.Kind() == reflect.Interface {
+		verb = "does not implement"
+	}
+	return fmt.Sprintf("plugin %q dispensed %s, which %s %s", e.Name, actual, verb, e.Expected)
+}
+
+// PluginDescriptor describes a plugin of a PluginSet along with the type
+// it dispenses. It is implemented by *TypedPlugin.
+type PluginDescriptor interface {
+	// Name is the name of the plugin in the PluginSet.
+	Name() string
+
+	// Plugin is the plugin implementation.
+	Plugin() Plugin
+
+	// Type is the type the plugin dispenses, usually an interface.
+	Type() reflect.Type
+}
+
+// TypedPlugin is a PluginDescriptor for a plugin dispensing a T. Hosts and
+// plugins usually share the descriptors of their plugins:
+//
+//	var KVPlugin = plugin.NewTypedPlugin[KV]("kv", &KVGRPCPlugin{})
+//
+// The host can then build its PluginSet with NewPluginSet and dispense with
+// KVPlugin.Dispense.
+type TypedPlugin[T any] struct {
+	name   string
+	plugin Plugin
+}
+
+var _ PluginDescriptor = (*TypedPlugin[any])(nil)
+
+// NewTypedPlugin returns the descriptor of the plugin p named name, which
+// dispenses a T.
+func NewTypedPlugin[T any](name string, p Plugin) *TypedPlugin[T] {
+	return &TypedPlugin[T]{name: name, plugin: p}
+}
+
+func (p *TypedPlugin[T]) Name() string {
+	return p.name
+}
+
+func (p *TypedPlugin[T]) Plugin() Plugin {
+	return p.plugin
+}
+
+func (p *TypedPlugin[T]) Type() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Dispense dispenses the plugin from c. See the Dispense function.
+func (p *TypedPlugin[T]) Dispense(c ClientProtocol) (T, error) {
+	return Dispense[T](c, p.name)
+}
+
+// NewPluginSet returns the PluginSet of the given plugins. It panics if two
+// plugins have the same name.
+func NewPluginSet(plugins ...PluginDescriptor) PluginSet {
+	set := make(PluginSet, len(plugins))
+	for _, p := range plugins {
+		if _, ok := set[p.Name()]; ok {
+			panic(fmt.Sprintf("plugin: duplicate plugin %q in plugin set", p.Name()))
+		}
+		set[p.Name()] = p.Plugin()
+	}
+	return set
+}
+
+// Check returns an error if any of the given plugins isn't in the set. It
+// can be used at startup to make sure that every plugin a host dispenses is
+// configured, rather than failing when it is first dispensed.
+func (s PluginSet) Check(plugins ...PluginDescriptor) error {
+	var missing []string
+	for _, p := range plugins {
+		if _, ok := s[p.Name()]; !ok {
+			missing = append(missing, fmt.Sprintf("%q (%s)", p.Name(), p.Type()))
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	valid := make([]string, 0, len(s))
+	for name := range s {
+		valid = append(valid, fmt.Sprintf("%q", name))
+	}
+	sort.Strings(valid)
+	return fmt.Errorf("unknown plugins %s, valid plugins are: [%s]",
+		strings.Join(missing, ", "), strings.Join(valid, ", "))
+}
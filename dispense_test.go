@@ -0,0 +1,92 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDispense(t *testing.T) {
+	client, _ := TestPluginRPCConn(t, testPluginMap, nil)
+	defer client.Close()
+
+	impl, err := Dispense[testInterface](client, "test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result := impl.Double(21); result != 42 {
+		t.Fatalf("bad: %#v", result)
+	}
+
+	// A plugin of the wrong type
+	_, err = Dispense[io.Reader](client, "test")
+	var typeErr *DispenseTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("bad: %#v", err)
+	}
+	if typeErr.Name != "test" || typeErr.Actual.String() != "*plugin.testInterfaceClient" {
+		t.Fatalf("bad: %#v", typeErr)
+	}
+	expected := `plugin "test" dispensed *plugin.testInterfaceClient, which does not implement io.Reader`
+	if err.Error() != expected {
+		t.Fatalf("bad: %s", err)
+	}
+
+	// An unknown plugin
+	if _, err := Dispense[testInterface](client, "nope"); err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func TestTypedPlugin(t *testing.T) {
+	testPlugin := NewTypedPlugin[testInterface]("test", new(testInterfacePlugin))
+	if testPlugin.Type().String() != "plugin.testInterface" {
+		t.Fatalf("bad: %s", testPlugin.Type())
+	}
+
+	client, _ := TestPluginRPCConn(t, NewPluginSet(testPlugin), nil)
+	defer client.Close()
+
+	impl, err := testPlugin.Dispense(client)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if result := impl.Double(21); result != 42 {
+		t.Fatalf("bad: %#v", result)
+	}
+}
+
+func TestNewPluginSet_duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("should panic")
+		}
+	}()
+
+	NewPluginSet(
+		NewTypedPlugin[testInterface]("test", new(testInterfacePlugin)),
+		NewTypedPlugin[testInterface]("test", new(testGRPCInterfacePlugin)),
+	)
+}
+
+func TestPluginSet_Check(t *testing.T) {
+	testPlugin := NewTypedPlugin[testInterface]("test", new(testInterfacePlugin))
+	otherPlugin := NewTypedPlugin[io.Reader]("other", new(testInterfacePlugin))
+
+	set := NewPluginSet(testPlugin)
+	if err := set.Check(testPlugin); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	err := set.Check(testPlugin, otherPlugin)
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(err.Error(), `"other" (io.Reader)`) || !strings.Contains(err.Error(), `["test"]`) {
+		t.Fatalf("bad: %s", err)
+	}
+}
@@ -7,8 +7,21 @@ package plugin
 // across RPC channels. Since "error" is an interface, we can't always
 // gob-encode the underlying structure. This is a valid error interface
 // implementer that we will push across.
+//
+// Errors registered with RegisterSentinelError or RegisterErrorType are
+// encoded along with their message, and so are the errors they wrap. On
+// the other side, errors.Is and errors.As find the registered errors in
+// the chain of the BasicError, and the messages of the others.
 type BasicError struct {
-	Message string
+	Message string `json:"message"`
+
+	// Code is the code the error was registered under, if any, and Data
+	// the JSON encoding of errors registered with RegisterErrorType.
+	Code string `json:"code,omitempty"`
+	Data []byte `json:"data,omitempty"`
+
+	// Wrapped are the encoded errors wrapped by the error.
+	Wrapped []*BasicError `json:"wrapped,omitempty"`
 }
 
 // NewBasicError is used to create a BasicError.
@@ -18,10 +31,46 @@ func NewBasicError(err error) *BasicError {
 	if err == nil {
 		return nil
 	}
+	if e, ok := err.(*BasicError); ok {
+		return e
+	}
+
+	e := &BasicError{Message: err.Error()}
+	e.Code, e.Data = encodeRegisteredError(err)
+
+	var wrapped []error
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		if w := u.Unwrap(); w != nil {
+			wrapped = []error{w}
+		}
+	case interface{ Unwrap() []error }:
+		wrapped = u.Unwrap()
+	}
+	for _, w := range wrapped {
+		if w != nil {
+			e.Wrapped = append(e.Wrapped, NewBasicError(w))
+		}
+	}
 
-	return &BasicError{err.Error()}
+	return e
 }
 
 func (e *BasicError) Error() string {
 	return e.Message
 }
+
+// Unwrap returns the registered error e was encoded from, if any, and the
+// errors it wrapped.
+func (e *BasicError) Unwrap() []error {
+	var errs []error
+	if decoded := decodeRegisteredError(e.Code, e.Data); decoded != nil {
+		errs = append(errs, decoded)
+	}
+	for _, w := range e.Wrapped {
+		if w != nil {
+			errs = append(errs, w)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,275 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorCodecs are the registered errors, by code and by sentinel value or
+// type.
+var errorCodecs = struct {
+	sync.RWMutex
+	byCode     map[string]errorCodec
+	sentinels  map[error]string
+	errorTypes map[reflect.Type]string
+}{
+	byCode:     make(map[string]errorCodec),
+	sentinels:  make(map[error]string),
+	errorTypes: make(map[reflect.Type]string),
+}
+
+// errorCodec decodes the errors registered under a code.
+type errorCodec struct {
+	sentinel  error
+	errorType reflect.Type
+}
+
+// RegisterSentinelError registers a sentinel error, such as io.EOF, under
+// the given code. Errors passed across the plugin boundary that are or wrap
+// err are then matched by errors.Is(err, sentinel) on the other side.
+//
+// Both the host and the plugin must register the error under the same code,
+// usually in the init function of a package they share. It panics if the
+// code or the error are already registered.
+func RegisterSentinelError(code string, err error) {
+	errorCodecs.Lock()
+	defer errorCodecs.Unlock()
+
+	checkErrorCode(code)
+	if other, ok := errorCodecs.sentinels[err]; ok {
+		panic(fmt.Sprintf("plugin: error %q is already registered as %q", err, other))
+	}
+	errorCodecs.byCode[code] = errorCodec{sentinel: err}
+	errorCodecs.sentinels[err] = code
+}
+
+// RegisterErrorType registers the error type T under the given code. Errors
+// of type T passed across the plugin boundary are encoded to JSON, and
+// decoded back into a T on the other side, so errors.As finds them. T is
+// usually a pointer to a struct with exported fields.
+//
+// Both the host and the plugin must register the type under the same code,
+// usually in the init function of a package they share. It panics if the
+// code or the type are already registered.
+func RegisterErrorType[T error](code string) {
+	errorCodecs.Lock()
+	defer errorCodecs.Unlock()
+
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() == reflect.Interface {
+		panic(fmt.Sprintf("plugin: can't register interface type %s as an error type", typ))
+	}
+
+	checkErrorCode(code)
+	if other, ok := errorCodecs.errorTypes[typ]; ok {
+		panic(fmt.Sprintf("plugin: error type %s is already registered as %q", typ, other))
+	}
+	errorCodecs.byCode[code] = errorCodec{errorType: typ}
+	errorCodecs.errorTypes[typ] = code
+}
+
+// checkErrorCode panics if code can't be registered. It must be called
+// with errorCodecs locked.
+func checkErrorCode(code string) {
+	if code == "" {
+		panic("plugin: error code must not be empty")
+	}
+	if _, ok := errorCodecs.byCode[code]; ok {
+		panic(fmt.Sprintf("plugin: error code %q is already registered", code))
+	}
+}
+
+// encodeRegisteredError returns the code err is registered under, and its
+// JSON encoding if it is of a registered type. Errors wrapped by err are not
+// considered.
+func encodeRegisteredError(err error) (string, []byte) {
+	errorCodecs.RLock()
+	defer errorCodecs.RUnlock()
+
+	if reflect.TypeOf(err).Comparable() {
+		if code, ok := errorCodecs.sentinels[err]; ok {
+			return code, nil
+		}
+	}
+
+	code, ok := errorCodecs.errorTypes[reflect.TypeOf(err)]
+	if !ok {
+		return "", nil
+	}
+	data, jsonErr := json.Marshal(err)
+	if jsonErr != nil {
+		// The message is still passed along.
+		return "", nil
+	}
+	return code, data
+}
+
+// decodeRegisteredError returns the error registered under code, or nil if
+// the code is unknown or data can't be decoded.
+func decodeRegisteredError(code string, data []byte) error {
+	if code == "" {
+		return nil
+	}
+
+	errorCodecs.RLock()
+	c, ok := errorCodecs.byCode[code]
+	errorCodecs.RUnlock()
+	if !ok {
+		return nil
+	}
+	if c.sentinel != nil {
+		return c.sentinel
+	}
+
+	ptr := reflect.New(c.errorType)
+	if c.errorType.Kind() == reflect.Pointer {
+		ptr.Elem().Set(reflect.New(c.errorType.Elem()))
+		if err := json.Unmarshal(data, ptr.Elem().Interface()); err != nil {
+			return nil
+		}
+	} else if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+		return nil
+	}
+	err, _ := ptr.Elem().Interface().(error)
+	return err
+}
+
+// errorDetailDomain is the domain of the ErrorInfo details carrying encoded
+// errors in gRPC statuses.
+const errorDetailDomain = "go-plugin"
+
+// errorDetailKey is the metadata key of the JSON encoded BasicError in the
+// ErrorInfo details.
+const errorDetailKey = "error"
+
+// grpcErrorServerOptions returns the interceptors encoding the errors of
+// gRPC servers into their status details.
+func grpcErrorServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			resp, err := handler(ctx, req)
+			return resp, encodeGRPCError(err)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			return encodeGRPCError(handler(srv, ss))
+		}),
+	}
+}
+
+// grpcErrorDialOptions returns the interceptors decoding the errors of gRPC
+// clients from their status details.
+func grpcErrorDialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return decodeGRPCError(invoker(ctx, method, req, reply, cc, opts...))
+		}),
+		grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			s, err := streamer(ctx, desc, cc, method, opts...)
+			if err != nil {
+				return nil, decodeGRPCError(err)
+			}
+			return &errorClientStream{ClientStream: s}, nil
+		}),
+	}
+}
+
+// encodeGRPCError turns an error returned by a gRPC handler into a status
+// carrying the encoded error. Errors that already have a status are
+// returned unchanged, and context errors get the status of their code.
+func encodeGRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	// Context errors keep the codes gRPC gives them, so the caller can tell
+	// that the call was canceled or timed out.
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+
+	data, jsonErr := json.Marshal(NewBasicError(err))
+	if jsonErr != nil {
+		return err
+	}
+	st, detailErr := status.New(codes.Unknown, err.Error()).WithDetails(&errdetails.ErrorInfo{
+		Domain:   errorDetailDomain,
+		Reason:   "error",
+		Metadata: map[string]string{errorDetailKey: string(data)},
+	})
+	if detailErr != nil {
+		return err
+	}
+	return st.Err()
+}
+
+// decodeGRPCError returns err with the error encoded by encodeGRPCError, if
+// any, in its chain.
+func decodeGRPCError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.Unknown {
+		return err
+	}
+
+	for _, d := range st.Details() {
+		info, ok := d.(*errdetails.ErrorInfo)
+		if !ok || info.Domain != errorDetailDomain {
+			continue
+		}
+		var decoded BasicError
+		if json.Unmarshal([]byte(info.Metadata[errorDetailKey]), &decoded) != nil {
+			continue
+		}
+		return &GRPCError{status: st, err: &decoded}
+	}
+	return err
+}
+
+// GRPCError is returned by gRPC calls to plugins and hosts that failed with
+// an error encoded by go-plugin. Its message and status are those of the
+// gRPC status, so it can be handled like any gRPC error, and it wraps the
+// *BasicError the error was encoded into, so errors.Is and errors.As find
+// the errors registered with RegisterSentinelError and RegisterErrorType.
+type GRPCError struct {
+	status *status.Status
+	err    *BasicError
+}
+
+func (e *GRPCError) Error() string {
+	return e.status.Err().Error()
+}
+
+// GRPCStatus returns the gRPC status of the error.
+func (e *GRPCError) GRPCStatus() *status.Status {
+	return e.status
+}
+
+func (e *GRPCError) Unwrap() error {
+	return e.err
+}
+
+// errorClientStream decodes the errors of a client stream.
+type errorClientStream struct {
+	grpc.ClientStream
+}
+
+func (s *errorClientStream) RecvMsg(m interface{}) error {
+	return decodeGRPCError(s.ClientStream.RecvMsg(m))
+}
+
+func (s *errorClientStream) SendMsg(m interface{}) error {
+	return decodeGRPCError(s.ClientStream.SendMsg(m))
+}
@@ -0,0 +1,178 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"testing"
+
+	grpctest "github.com/hashicorp/go-plugin/test/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errTestNotFound = errors.New("not found")
+
+type testQuotaError struct {
+	Resource string
+	Limit    int
+}
+
+func (e *testQuotaError) Error() string {
+	return fmt.Sprintf("quota of %d %s exceeded", e.Limit, e.Resource)
+}
+
+func init() {
+	RegisterSentinelError("test.not_found", errTestNotFound)
+	RegisterErrorType[*testQuotaError]("test.quota")
+}
+
+// testCodecError returns an error chain with registered and unregistered
+// errors.
+func testCodecError() error {
+	return fmt.Errorf("put: %w", errors.Join(
+		fmt.Errorf("lookup: %w", errTestNotFound),
+		&testQuotaError{Resource: "keys", Limit: 10},
+		errors.New("unregistered"),
+	))
+}
+
+// checkCodecError checks that err has the errors of testCodecError in its
+// chain.
+func checkCodecError(t *testing.T, err error) {
+	t.Helper()
+
+	if !errors.Is(err, errTestNotFound) {
+		t.Fatalf("expected %v to be errTestNotFound", err)
+	}
+	var quotaErr *testQuotaError
+	if !errors.As(err, &quotaErr) {
+		t.Fatalf("expected %v to be a *testQuotaError", err)
+	}
+	if quotaErr.Resource != "keys" || quotaErr.Limit != 10 {
+		t.Fatalf("bad: %#v", quotaErr)
+	}
+
+	// Unregistered errors keep their message.
+	found := false
+	var walk func(error)
+	walk = func(err error) {
+		if err.Error() == "unregistered" {
+			found = true
+		}
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			walk(u.Unwrap())
+		case interface{ Unwrap() []error }:
+			for _, e := range u.Unwrap() {
+				walk(e)
+			}
+		}
+	}
+	walk(err)
+	if !found {
+		t.Fatalf("unregistered error not found in %v", err)
+	}
+}
+
+func TestBasicError_gob(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(NewBasicError(testCodecError())); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var decoded *BasicError
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if decoded.Error() != testCodecError().Error() {
+		t.Fatalf("bad: %q", decoded.Error())
+	}
+	checkCodecError(t, decoded)
+}
+
+func TestBasicError_unknownCode(t *testing.T) {
+	err := &BasicError{Message: "gone", Code: "test.unknown"}
+	if len(err.Unwrap()) != 0 {
+		t.Fatalf("bad: %#v", err.Unwrap())
+	}
+}
+
+func TestRegisterSentinelError_duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+	RegisterSentinelError("test.not_found", errors.New("other"))
+}
+
+// errPingPongServer fails every ping with err.
+type errPingPongServer struct {
+	grpctest.UnimplementedPingPongServer
+	err error
+}
+
+func (p *errPingPongServer) Ping(context.Context, *grpctest.PingRequest) (*grpctest.PongResponse, error) {
+	return nil, p.err
+}
+
+func TestGRPCError(t *testing.T) {
+	client, server := TestPluginGRPCConn(t, false, map[string]Plugin{
+		"test": new(testGRPCInterfacePlugin),
+	})
+	defer func() { _ = client.Close() }()
+	defer server.Stop()
+
+	for name, tc := range map[string]struct {
+		err  error
+		code codes.Code
+	}{
+		"encoded":  {testCodecError(), codes.Unknown},
+		"status":   {status.Error(codes.NotFound, "no such key"), codes.NotFound},
+		"canceled": {context.Canceled, codes.Canceled},
+		"deadline": {fmt.Errorf("waiting for lock: %w", context.DeadlineExceeded), codes.DeadlineExceeded},
+	} {
+		serverErr := tc.err
+		t.Run(name, func(t *testing.T) {
+			s, err := server.broker.ServeService("pingpong."+name, func(s *grpc.Server) {
+				grpctest.RegisterPingPongServer(s, &errPingPongServer{err: serverErr})
+			})
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			defer func() { _ = s.Close() }()
+
+			conn, err := client.broker.DialService(context.Background(), "pingpong."+name)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			defer func() { _ = conn.Close() }()
+
+			_, err = grpctest.NewPingPongClient(conn).Ping(context.Background(), &grpctest.PingRequest{})
+			if err == nil {
+				t.Fatal("expected error")
+			}
+
+			// The error still looks like any gRPC error.
+			st, ok := status.FromError(err)
+			if !ok || st.Message() != status.Convert(serverErr).Message() {
+				t.Fatalf("bad: %v", err)
+			}
+
+			if st.Code() != tc.code {
+				t.Fatalf("bad: %v", st.Code())
+			}
+			if name == "encoded" {
+				checkCodecError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Code generated by protoc-gen-go-plugin. DO NOT EDIT.
+// source: proto/kv.proto
+
+package proto
+
+import (
+	context "context"
+	errors "errors"
+	fmt "fmt"
+	go_plugin "github.com/hashicorp/go-plugin"
+	grpc "google.golang.org/grpc"
+	io "io"
+	sync "sync"
+)
+
+// CounterPluginName is the name Counter plugins are served and dispensed
+// with.
+const CounterPluginName = "counter"
+
+// CounterHandshake is the handshake configuration shared by hosts and Counter
+// plugins.
+var CounterHandshake = go_plugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "COUNTER_PLUGIN",
+	MagicCookieValue: "6a7eab64f67ef003acf2f745436c16c4",
+}
+
+// CounterPlugin is the plugin.GRPCPlugin for the Counter service.
+type CounterPlugin struct {
+	go_plugin.NetRPCUnsupportedPlugin
+
+	// Impl is the implementation served by the plugin.
+	Impl CounterServer
+
+	// ImplFunc, if set, is used instead of Impl to create the
+	// implementation with access to the broker, for example to dial
+	// callbacks.
+	ImplFunc func(*go_plugin.GRPCBroker) CounterServer
+}
+
+func (p *CounterPlugin) GRPCServer(broker *go_plugin.GRPCBroker, s *grpc.Server) error {
+	impl := p.Impl
+	if p.ImplFunc != nil {
+		impl = p.ImplFunc(broker)
+	}
+	if impl == nil {
+		return errors.New("no Counter implementation")
+	}
+
+	RegisterCounterServer(s, impl)
+	return nil
+}
+
+func (p *CounterPlugin) GRPCClient(ctx context.Context, broker *go_plugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
+	return &CounterPluginClient{
+		CounterClient: NewCounterClient(c),
+		Broker:        broker,
+	}, nil
+}
+
+// CounterPluginClient is the host's client for a Counter plugin.
+type CounterPluginClient struct {
+	CounterClient
+
+	// Broker is used to serve callbacks to the plugin.
+	Broker *go_plugin.GRPCBroker
+}
+
+// CounterPluginMap returns the plugins hosts should set in
+// plugin.ClientConfig to use Counter plugins.
+func CounterPluginMap() map[string]go_plugin.Plugin {
+	return map[string]go_plugin.Plugin{
+		CounterPluginName: &CounterPlugin{},
+	}
+}
+
+// ServeCounter serves p as a Counter plugin. It is meant to be called from
+// the plugin's main function.
+func ServeCounter(p *CounterPlugin) {
+	go_plugin.Serve(&go_plugin.ServeConfig{
+		HandshakeConfig: CounterHandshake,
+		Plugins:         go_plugin.PluginSet{CounterPluginName: p},
+		GRPCServer:      go_plugin.DefaultGRPCServer,
+	})
+}
+
+// DispenseCounter dispenses the Counter plugin from a client using
+// CounterPluginMap.
+func DispenseCounter(c go_plugin.ClientProtocol) (*CounterPluginClient, error) {
+	raw, err := c.Dispense(CounterPluginName)
+	if err != nil {
+		return nil, err
+	}
+
+	client, ok := raw.(*CounterPluginClient)
+	if !ok {
+		return nil, fmt.Errorf("plugin %q is %T, not *CounterPluginClient", CounterPluginName, raw)
+	}
+
+	return client, nil
+}
+
+// ServeAddHelperCallback serves impl to the other side of broker. The returned
+// broker ID should be sent to it, and stop called once the callback is no
+// longer needed.
+func ServeAddHelperCallback(broker *go_plugin.GRPCBroker, impl AddHelperServer) (id uint32, stop func()) {
+	var lock sync.Mutex
+	var server *grpc.Server
+	var stopped bool
+
+	id = broker.NextId()
+	go broker.AcceptAndServe(id, func(opts []grpc.ServerOption) *grpc.Server {
+		s := grpc.NewServer(opts...)
+		RegisterAddHelperServer(s, impl)
+
+		lock.Lock()
+		defer lock.Unlock()
+		if stopped {
+			s.Stop()
+		}
+		server = s
+		return s
+	})
+
+	return id, func() {
+		lock.Lock()
+		defer lock.Unlock()
+		stopped = true
+		if server != nil {
+			server.Stop()
+		}
+	}
+}
+
+// DialAddHelperCallback connects to the AddHelper served by the other side of
+// broker with the given ID. The returned closer should be closed once done.
+func DialAddHelperCallback(broker *go_plugin.GRPCBroker, id uint32) (AddHelperClient, io.Closer, error) {
+	conn, err := broker.Dial(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return NewAddHelperClient(conn), conn, nil
+}
+
+// ServeAddServer serves impl as the AddServer callback, setting AddServer to
+// its broker ID. stop should be called once the request has completed.
+func (x *PutRequest) ServeAddServer(broker *go_plugin.GRPCBroker, impl AddHelperServer) (stop func()) {
+	x.AddServer, stop = ServeAddHelperCallback(broker, impl)
+	return stop
+}
+
+// DialAddServer connects to the AddHelper callback given by AddServer.
+func (x *PutRequest) DialAddServer(broker *go_plugin.GRPCBroker) (AddHelperClient, io.Closer, error) {
+	return DialAddHelperCallback(broker, x.GetAddServer())
+}
@@ -0,0 +1,277 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/yamux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrPluginExited is matched by errors.Is for the errors of calls that
+// failed because the plugin process exited. See PluginExitedError.
+var ErrPluginExited = errors.New("plugin exited")
+
+// exitedWaitTimeout is how long a call failing because the connection to the
+// plugin broke waits for the plugin process to be reported as exited. The
+// connections of a process break as it exits, slightly before it is reaped,
+// but they also break if the plugin closes them while it keeps running, so
+// the wait is kept short.
+const exitedWaitTimeout = 250 * time.Millisecond
+
+// PluginExitedError is the error of a call to a plugin that failed because
+// the plugin process exited, for example because it crashed. It wraps
+// ErrPluginExited and the error of the call, so gRPC status codes are still
+// available.
+//
+// Calls over GRPCClient.Conn and calls by the plugins dispensed by an
+// RPCClient return them. Other errors can be classified with
+// Client.CheckExited.
+type PluginExitedError struct {
+	// Err is the error of the failed call.
+	Err error
+
+	// ExitErr is the error the plugin process exited with, such as an
+	// *exec.ExitError, or nil if it exited successfully.
+	ExitErr error
+}
+
+func (e *PluginExitedError) Error() string {
+	if e.ExitErr != nil {
+		return fmt.Sprintf("plugin exited (%s): %s", e.ExitErr, e.Err)
+	}
+	return fmt.Sprintf("plugin exited: %s", e.Err)
+}
+
+func (e *PluginExitedError) Unwrap() []error {
+	return []error{ErrPluginExited, e.Err}
+}
+
+// ExitCode returns the exit code of the plugin process, or -1 if it is
+// unknown, for example if the process was killed by a signal.
+func (e *PluginExitedError) ExitCode() int {
+	if e.ExitErr == nil {
+		return 0
+	}
+	var exitErr interface{ ExitCode() int }
+	if errors.As(e.ExitErr, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// IsPluginExited returns true if err is the error of a call that failed
+// because the plugin process exited.
+func IsPluginExited(err error) bool {
+	return errors.Is(err, ErrPluginExited)
+}
+
+// CheckExited returns err as a *PluginExitedError if it is the error of a
+// broken connection to the plugin and the plugin process has exited, and
+// err unchanged otherwise. Errors of calls made after the connection broke,
+// such as rpc.ErrShutdown, can be classified this way.
+//
+// CheckExited doesn't wait: if the process hasn't been reported as exited
+// yet, err is returned unchanged.
+func (c *Client) CheckExited(err error) error {
+	c.l.Lock()
+	doneCtx := c.doneCtx
+	c.l.Unlock()
+
+	return checkExited(doneCtx, err, false)
+}
+
+// checkExited is CheckExited for the process whose exit cancels doneCtx. If
+// wait is true, it waits for the process to be reported as exited for a
+// short while.
+func checkExited(doneCtx context.Context, err error, wait bool) error {
+	if err == nil || doneCtx == nil || IsPluginExited(err) || !isConnError(err) {
+		return err
+	}
+
+	if wait {
+		timer := time.NewTimer(exitedWaitTimeout)
+		defer timer.Stop()
+		select {
+		case <-doneCtx.Done():
+		case <-timer.C:
+			return err
+		}
+	} else if doneCtx.Err() == nil {
+		return err
+	}
+
+	exitedErr := &PluginExitedError{Err: err}
+	var cause *exitCause
+	if errors.As(context.Cause(doneCtx), &cause) {
+		exitedErr.ExitErr = cause.err
+	}
+	return exitedErr
+}
+
+// exitChecker classifies the errors of the calls of a protocol client. Calls
+// only wait for the plugin process to be reported as exited once one of the
+// connections of the client broke, so that calls failing while the plugin
+// is running, such as calls returning an Unavailable status, fail at once.
+type exitChecker struct {
+	doneCtx context.Context
+	broken  atomic.Bool
+}
+
+func newExitChecker(doneCtx context.Context) *exitChecker {
+	return &exitChecker{doneCtx: doneCtx}
+}
+
+// check classifies err, see CheckExited.
+func (e *exitChecker) check(err error) error {
+	return checkExited(e.doneCtx, err, e.broken.Load())
+}
+
+// wrapConn returns conn recording when it breaks.
+func (e *exitChecker) wrapConn(conn net.Conn) net.Conn {
+	return &exitCheckerConn{Conn: conn, broken: &e.broken}
+}
+
+// exitCheckerConn sets broken once a read or write fails, unless the
+// connection was closed by this side.
+type exitCheckerConn struct {
+	net.Conn
+	broken *atomic.Bool
+	closed atomic.Bool
+}
+
+func (c *exitCheckerConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.failed(err)
+	return n, err
+}
+
+func (c *exitCheckerConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.failed(err)
+	return n, err
+}
+
+func (c *exitCheckerConn) Close() error {
+	c.closed.Store(true)
+	return c.Conn.Close()
+}
+
+func (c *exitCheckerConn) failed(err error) {
+	if err != nil && !c.closed.Load() && !errors.Is(err, net.ErrClosed) && !errors.Is(err, os.ErrDeadlineExceeded) {
+		c.broken.Store(true)
+	}
+}
+
+// exitCause is the cause of the cancellation of the DoneCtx of a client,
+// carrying the error the plugin process exited with.
+type exitCause struct {
+	err error
+}
+
+func (e *exitCause) Error() string {
+	return "plugin exited"
+}
+
+// isConnError returns true if err may be caused by the connection to the
+// plugin breaking.
+func isConnError(err error) bool {
+	if s, ok := status.FromError(err); ok {
+		return s.Code() == codes.Unavailable
+	}
+	for _, target := range []error{
+		io.EOF,
+		io.ErrUnexpectedEOF,
+		io.ErrClosedPipe,
+		net.ErrClosed,
+		rpc.ErrShutdown,
+		yamux.ErrSessionShutdown,
+		yamux.ErrStreamClosed,
+		yamux.ErrConnectionReset,
+		syscall.ECONNRESET,
+		syscall.EPIPE,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// exitedDialOptions returns the interceptors classifying the errors of gRPC
+// calls with check.
+func exitedDialOptions(check func(error) error) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return check(invoker(ctx, method, req, reply, cc, opts...))
+		}),
+		grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			s, err := streamer(ctx, desc, cc, method, opts...)
+			if err != nil {
+				return nil, check(err)
+			}
+			return &exitedClientStream{ClientStream: s, check: check}, nil
+		}),
+	}
+}
+
+// exitedClientStream classifies the errors of a client stream.
+type exitedClientStream struct {
+	grpc.ClientStream
+	check func(error) error
+}
+
+func (s *exitedClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == io.EOF {
+		// The end of the stream.
+		return err
+	}
+	return s.check(err)
+}
+
+func (s *exitedClientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == io.EOF {
+		// The actual error is returned by RecvMsg.
+		return err
+	}
+	return s.check(err)
+}
+
+// exitedConn classifies the errors of a connection used by a net/rpc
+// client. The client fails the pending calls with the errors of the
+// connection, other than io.EOF.
+type exitedConn struct {
+	net.Conn
+	check func(error) error
+}
+
+func (c *exitedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if err != nil {
+		err = c.check(err)
+	}
+	return n, err
+}
+
+func (c *exitedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if err != nil {
+		err = c.check(err)
+	}
+	return n, err
+}
@@ -0,0 +1,132 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPluginExitedError(t *testing.T) {
+	callErr := status.Error(codes.Unavailable, "connection closed")
+	err := &PluginExitedError{Err: callErr}
+	if !IsPluginExited(err) || !errors.Is(err, callErr) {
+		t.Fatalf("bad: %v", err)
+	}
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("bad: %v", status.Code(err))
+	}
+	if code := err.ExitCode(); code != 0 {
+		t.Fatalf("bad: %d", code)
+	}
+
+	err.ExitErr = errors.New("signal: killed")
+	if code := err.ExitCode(); code != -1 {
+		t.Fatalf("bad: %d", code)
+	}
+
+	if IsPluginExited(io.EOF) {
+		t.Fatal("io.EOF should not be a plugin exited error")
+	}
+}
+
+func TestClient_pluginExited(t *testing.T) {
+	for name, tc := range testProtocols {
+		t.Run(name, func(t *testing.T) {
+			c := NewClient(&ClientConfig{
+				Cmd:              helperProcess(tc.helper),
+				HandshakeConfig:  testHandshake,
+				Plugins:          tc.plugins,
+				AllowedProtocols: []Protocol{ProtocolNetRPC, ProtocolGRPC},
+			})
+			defer c.Kill()
+
+			client, err := c.Client()
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			raw, err := client.Dispense("test")
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			// The plugin crashes while serving the call.
+			err = raw.(testInterface).Panic("crash")
+			if !IsPluginExited(err) {
+				t.Fatalf("expected plugin exited error, got %v", err)
+			}
+			var exitedErr *PluginExitedError
+			if !errors.As(err, &exitedErr) {
+				t.Fatalf("bad: %#v", err)
+			}
+			if code := exitedErr.ExitCode(); code != 2 {
+				t.Fatalf("bad exit code: %d", code)
+			}
+			var exitErr *exec.ExitError
+			if !errors.As(exitedErr.ExitErr, &exitErr) {
+				t.Fatalf("bad: %#v", exitedErr.ExitErr)
+			}
+
+			// Errors of later calls are classified by CheckExited.
+			if err := c.CheckExited(client.Ping()); !IsPluginExited(err) {
+				t.Fatalf("expected plugin exited error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestClient_CheckExited_running(t *testing.T) {
+	c := NewClient(&ClientConfig{
+		Cmd:             helperProcess("test-interface"),
+		HandshakeConfig: testHandshake,
+		Plugins:         testPluginMap,
+	})
+	defer c.Kill()
+
+	if _, err := c.Client(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Errors that aren't caused by a broken connection are unchanged.
+	callErr := errors.New("bad request")
+	if err := c.CheckExited(callErr); err != callErr {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestExitChecker(t *testing.T) {
+	doneCtx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	exits := newExitChecker(doneCtx)
+
+	// Calls failing while the connections are fine, such as calls returning
+	// an Unavailable status, fail at once.
+	callErr := status.Error(codes.Unavailable, "try again later")
+	start := time.Now()
+	if err := exits.check(callErr); err != callErr {
+		t.Fatalf("bad: %v", err)
+	}
+	if d := time.Since(start); d >= exitedWaitTimeout {
+		t.Fatalf("check waited %s", d)
+	}
+
+	// Once a connection broke, calls wait for the plugin to exit.
+	exits.broken.Store(true)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel(&exitCause{err: errors.New("exit status 2")})
+	}()
+	err := exits.check(callErr)
+	var exitedErr *PluginExitedError
+	if !errors.As(err, &exitedErr) || exitedErr.ExitErr == nil {
+		t.Fatalf("bad: %#v", err)
+	}
+}
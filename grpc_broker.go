@@ -20,7 +20,9 @@ import (
 
 	"github.com/oklog/run"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 )
 
 // streamer interface is used in the broker to send/receive connection
@@ -54,6 +56,19 @@ type gRPCBrokerServer struct {
 
 	// o is used to ensure we close the quit channel only once.
 	o sync.Once
+
+	// persistent keeps the broker open when a stream ends, so that another
+	// client can start a new stream. Used in debug mode.
+	persistent bool
+
+	// endSession is called when a stream of a persistent broker ends, to
+	// forget the state of the client that detached.
+	endSession func()
+
+	// streaming is set while a client has a stream open on a persistent
+	// broker. Only one client can be attached at a time.
+	streaming bool
+	lock      sync.Mutex
 }
 
 func newGRPCBrokerServer() *gRPCBrokerServer {
@@ -69,7 +84,26 @@ func newGRPCBrokerServer() *gRPCBrokerServer {
 // connection information to/from the client.
 func (s *gRPCBrokerServer) StartStream(stream plugin.GRPCBroker_StartStreamServer) error {
 	doneCh := stream.Context().Done()
-	defer s.Close()
+	if s.persistent {
+		s.lock.Lock()
+		if s.streaming {
+			s.lock.Unlock()
+			return status.Error(codes.FailedPrecondition, "another client is already attached to the plugin")
+		}
+		s.streaming = true
+		s.lock.Unlock()
+
+		defer func() {
+			if s.endSession != nil {
+				s.endSession()
+			}
+			s.lock.Lock()
+			s.streaming = false
+			s.lock.Unlock()
+		}()
+	} else {
+		defer s.Close()
+	}
 
 	// Proccess send stream
 	go func() {
@@ -276,6 +310,16 @@ type GRPCBroker struct {
 
 	muxer grpcmux.GRPCMuxer
 
+	// services are the named services announced by the other side.
+	// servicesCh is closed and replaced whenever they change.
+	services   map[string]*plugin.ConnInfo
+	servicesCh chan struct{}
+
+	// localServices are the named services served by this side.
+	localServices map[string]*BrokerService
+
+	registry *brokerRegistry
+
 	sync.Mutex
 }
 
@@ -283,9 +327,12 @@ type gRPCBrokerPending struct {
 	ch     chan *plugin.ConnInfo
 	doneCh chan struct{}
 	once   sync.Once
+
+	// closeCh is closed when the ID is closed with CloseID.
+	closeCh chan struct{}
 }
 
-func newGRPCBroker(s streamer, tls *tls.Config, unixSocketCfg UnixSocketConfig, addrTranslator runner.AddrTranslator, muxer grpcmux.GRPCMuxer) *GRPCBroker {
+func newGRPCBroker(s streamer, tls *tls.Config, unixSocketCfg UnixSocketConfig, addrTranslator runner.AddrTranslator, muxer grpcmux.GRPCMuxer, config *BrokerConfig) *GRPCBroker {
 	return &GRPCBroker{
 		streamer: s,
 		tls:      tls,
@@ -295,6 +342,11 @@ func newGRPCBroker(s streamer, tls *tls.Config, unixSocketCfg UnixSocketConfig,
 		serverStreams: make(map[uint32]*gRPCBrokerPending),
 		muxer:         muxer,
 
+		services:      make(map[string]*plugin.ConnInfo),
+		servicesCh:    make(chan struct{}),
+		localServices: make(map[string]*BrokerService),
+		registry:      newBrokerRegistry(config),
+
 		unixSocketCfg:  unixSocketCfg,
 		addrTranslator: addrTranslator,
 	}
@@ -337,32 +389,45 @@ func (b *GRPCBroker) Accept(id uint32) (net.Listener, error) {
 			},
 		}
 
-		return ln, nil
+		return b.registry.trackListener(id, ln), nil
 	}
 
-	listener, err := serverListener(b.unixSocketCfg)
+	listener, advertiseNet, advertiseAddr, err := b.listen()
 	if err != nil {
 		return nil, err
 	}
-
-	advertiseNet := listener.Addr().Network()
-	advertiseAddr := listener.Addr().String()
-	if b.addrTranslator != nil {
-		advertiseNet, advertiseAddr, err = b.addrTranslator.HostToPlugin(advertiseNet, advertiseAddr)
-		if err != nil {
-			return nil, err
-		}
-	}
 	err = b.streamer.Send(&plugin.ConnInfo{
 		ServiceId: id,
 		Network:   advertiseNet,
 		Address:   advertiseAddr,
 	})
 	if err != nil {
+		_ = listener.Close()
 		return nil, err
 	}
 
-	return listener, nil
+	return b.registry.trackListener(id, listener), nil
+}
+
+// listen opens a new listener when multiplexing is disabled, and returns it
+// along with the network and address to advertise to the other side.
+func (b *GRPCBroker) listen() (net.Listener, string, string, error) {
+	listener, err := serverListener(b.unixSocketCfg)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	advertiseNet := listener.Addr().Network()
+	advertiseAddr := listener.Addr().String()
+	if b.addrTranslator != nil {
+		advertiseNet, advertiseAddr, err = b.addrTranslator.HostToPlugin(advertiseNet, advertiseAddr)
+		if err != nil {
+			_ = listener.Close()
+			return nil, "", "", err
+		}
+	}
+
+	return listener, advertiseNet, advertiseAddr, nil
 }
 
 // AcceptAndServe is used to accept a specific stream ID and immediately
@@ -380,12 +445,7 @@ func (b *GRPCBroker) AcceptAndServe(id uint32, newGRPCServer func([]grpc.ServerO
 	}
 	defer func() { _ = ln.Close() }()
 
-	var opts []grpc.ServerOption
-	if b.tls != nil {
-		opts = []grpc.ServerOption{grpc.Creds(credentials.NewTLS(b.tls))}
-	}
-
-	server := newGRPCServer(opts)
+	server := newGRPCServer(b.serverOptions())
 
 	// Here we use a run group to close this goroutine if the server is shutdown
 	// or the broker is shutdown.
@@ -417,6 +477,16 @@ func (b *GRPCBroker) AcceptAndServe(id uint32, newGRPCServer func([]grpc.ServerO
 	_ = g.Run()
 }
 
+// serverOptions returns the options of the gRPC servers served on the
+// broker.
+func (b *GRPCBroker) serverOptions() []grpc.ServerOption {
+	var opts []grpc.ServerOption
+	if b.tls != nil {
+		opts = []grpc.ServerOption{grpc.Creds(credentials.NewTLS(b.tls))}
+	}
+	return append(opts, grpcErrorServerOptions()...)
+}
+
 // Close closes the stream and all servers.
 func (b *GRPCBroker) Close() error {
 	b.streamer.Close()
@@ -491,7 +561,10 @@ func (b *GRPCBroker) knock(id uint32) error {
 		if msg.Knock.Error != "" {
 			return fmt.Errorf("failed to knock for id %d: %s", id, msg.Knock.Error)
 		}
-	case <-time.After(5 * time.Second):
+	case <-p.closeCh:
+		return errBrokerIDClosed(id)
+	case <-time.After(b.registry.timeout()):
+		b.registry.expire(id)
 		return fmt.Errorf("timeout waiting for multiplexing knock handshake on id %d", id)
 	}
 
@@ -500,8 +573,12 @@ func (b *GRPCBroker) knock(id uint32) error {
 
 func (b *GRPCBroker) muxDial(id uint32) func(context.Context, string) (net.Conn, error) {
 	return func(context.Context, string) (net.Conn, error) {
-		b.dialMutex.Lock()
-		defer b.dialMutex.Unlock()
+		// Without stream IDs, the other side gives the next stream to the
+		// ID of the last knock, so dials must not be interleaved.
+		if !b.muxer.StreamIDs() {
+			b.dialMutex.Lock()
+			defer b.dialMutex.Unlock()
+		}
 
 		// Tell the other side the listener ID it should give the next stream to.
 		err := b.knock(id)
@@ -509,7 +586,7 @@ func (b *GRPCBroker) muxDial(id uint32) func(context.Context, string) (net.Conn,
 			return nil, fmt.Errorf("failed to knock before dialling client: %w", err)
 		}
 
-		conn, err := b.muxer.Dial()
+		conn, err := b.muxer.Dial(id)
 		if err != nil {
 			return nil, err
 		}
@@ -524,20 +601,34 @@ func (b *GRPCBroker) Dial(id uint32) (conn *grpc.ClientConn, err error) { return
 // Dial opens a connection by ID with options.
 func (b *GRPCBroker) DialWithOptions(id uint32, opts ...grpc.DialOption) (conn *grpc.ClientConn, err error) {
 	if b.muxer.Enabled() {
-		return dialGRPCConn(b.tls, b.muxDial(id), opts...)
+		return b.muxDialGRPC(id, opts...)
+	}
+
+	c, err := b.connInfo(id)
+	if err != nil {
+		return nil, err
 	}
 
-	var c *plugin.ConnInfo
+	return b.dialConnInfo(c, opts...)
+}
 
-	// Open the stream
+// connInfo waits for the other side to send the connection info of id.
+func (b *GRPCBroker) connInfo(id uint32) (*plugin.ConnInfo, error) {
 	p := b.getClientStream(id)
 	select {
-	case c = <-p.ch:
+	case c := <-p.ch:
 		close(p.doneCh)
-	case <-time.After(5 * time.Second):
+		return c, nil
+	case <-p.closeCh:
+		return nil, errBrokerIDClosed(id)
+	case <-time.After(b.registry.timeout()):
+		b.registry.expire(id)
 		return nil, fmt.Errorf("timeout waiting for connection info")
 	}
+}
 
+// connInfoAddr returns the address advertised in c.
+func (b *GRPCBroker) connInfoAddr(c *plugin.ConnInfo) (addr net.Addr, err error) {
 	network, address := c.Network, c.Address
 	if b.addrTranslator != nil {
 		network, address, err = b.addrTranslator.PluginToHost(network, address)
@@ -546,20 +637,92 @@ func (b *GRPCBroker) DialWithOptions(id uint32, opts ...grpc.DialOption) (conn *
 		}
 	}
 
-	var addr net.Addr
 	switch network {
 	case "tcp":
-		addr, err = net.ResolveTCPAddr("tcp", address)
+		return net.ResolveTCPAddr("tcp", address)
 	case "unix":
-		addr, err = net.ResolveUnixAddr("unix", address)
+		return net.ResolveUnixAddr("unix", address)
 	default:
-		err = fmt.Errorf("unknown address type: %s", c.Address)
+		return nil, fmt.Errorf("unknown address type: %s", c.Address)
+	}
+}
+
+// dialConnInfo opens a connection to the address advertised in c.
+func (b *GRPCBroker) dialConnInfo(c *plugin.ConnInfo, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	addr, err := b.connInfoAddr(c)
+	if err != nil {
+		return nil, err
 	}
+
+	conn, err := dialGRPCConn(b.tls, netAddrDialer(addr), opts...)
+	if err != nil {
+		return nil, err
+	}
+	b.registry.trackClientConn(c.ServiceId, addr, conn)
+
+	return conn, nil
+}
+
+// muxDialGRPC opens a connection to id over the multiplexed connection.
+func (b *GRPCBroker) muxDialGRPC(id uint32, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	conn, err := dialGRPCConn(b.tls, b.muxDial(id), opts...)
 	if err != nil {
 		return nil, err
 	}
+	b.registry.trackClientConn(id, nil, conn)
+
+	return conn, nil
+}
+
+// Connections returns the listeners and connections currently open on the
+// broker by this side, sorted by ID. Listeners, including those of services
+// served with ServeService, are listed as BrokerAccept and connections as
+// BrokerDial.
+func (b *GRPCBroker) Connections() []BrokerConn {
+	return b.registry.list()
+}
+
+// CloseID closes the listeners and connections open on the broker with the
+// given ID, and makes a pending Dial of the ID return an error.
+func (b *GRPCBroker) CloseID(id uint32) error {
+	b.Lock()
+	p, ok := b.clientStreams[id]
+	if ok {
+		delete(b.clientStreams, id)
+		close(p.closeCh)
+	}
+	b.Unlock()
+
+	return b.registry.closeID(id)
+}
+
+// resetSession forgets the state left by a client that detached from a
+// persistent broker, so that it isn't handed to the next client: pending
+// Dials of its IDs fail, and its unclaimed connection info, knocks and
+// services are dropped.
+func (b *GRPCBroker) resetSession() {
+	b.Lock()
+	defer b.Unlock()
+
+	for id, p := range b.clientStreams {
+		delete(b.clientStreams, id)
+		close(p.closeCh)
+	}
+	for _, p := range b.serverStreams {
+		select {
+		case <-p.ch:
+		default:
+		}
+	}
+
+	b.services = make(map[string]*plugin.ConnInfo)
+	close(b.servicesCh)
+	b.servicesCh = make(chan struct{})
+}
 
-	return dialGRPCConn(b.tls, netAddrDialer(addr), opts...)
+// Stats returns counters of the broker's listeners, connections and IDs.
+func (b *GRPCBroker) Stats() BrokerStats {
+	return b.registry.stats()
 }
 
 // NextId returns a unique ID to use next.
@@ -584,6 +747,12 @@ func (m *GRPCBroker) Run() {
 			break
 		}
 
+		// Named services are announced separately from connection info.
+		if msg.Service != nil {
+			m.updateService(msg)
+			continue
+		}
+
 		// Initialize the waiter
 		var p *gRPCBrokerPending
 		if msg.Knock != nil && msg.Knock.Knock && !msg.Knock.Ack {
@@ -613,8 +782,9 @@ func (m *GRPCBroker) getClientStream(id uint32) *gRPCBrokerPending {
 	}
 
 	m.clientStreams[id] = &gRPCBrokerPending{
-		ch:     make(chan *plugin.ConnInfo, 1),
-		doneCh: make(chan struct{}),
+		ch:      make(chan *plugin.ConnInfo, 1),
+		doneCh:  make(chan struct{}),
+		closeCh: make(chan struct{}),
 	}
 	return m.clientStreams[id]
 }
@@ -631,8 +801,9 @@ func (m *GRPCBroker) getServerStream(id uint32) *gRPCBrokerPending {
 	}
 
 	m.serverStreams[id] = &gRPCBrokerPending{
-		ch:     make(chan *plugin.ConnInfo, 1),
-		doneCh: make(chan struct{}),
+		ch:      make(chan *plugin.ConnInfo, 1),
+		doneCh:  make(chan struct{}),
+		closeCh: make(chan struct{}),
 	}
 	return m.serverStreams[id]
 }
@@ -640,14 +811,33 @@ func (m *GRPCBroker) getServerStream(id uint32) *gRPCBrokerPending {
 func (m *GRPCBroker) timeoutWait(id uint32, p *gRPCBrokerPending) {
 	// Wait for the stream to either be picked up and connected, or
 	// for a timeout.
+	timeout := false
 	select {
 	case <-p.doneCh:
-	case <-time.After(5 * time.Second):
+	case <-p.closeCh:
+		return
+	case <-time.After(m.registry.timeout()):
+		timeout = true
 	}
 
 	m.Lock()
 	defer m.Unlock()
 
 	// Delete the stream so no one else can grab it
-	delete(m.clientStreams, id)
+	if m.clientStreams[id] == p {
+		delete(m.clientStreams, id)
+	}
+
+	// Connection info that was never dialled is for a listener the other
+	// side is still holding open. Late knock acks are already reported by
+	// knock.
+	if timeout {
+		select {
+		case msg := <-p.ch:
+			if msg.Knock == nil {
+				m.registry.leak(id)
+			}
+		default:
+		}
+	}
 }
@@ -0,0 +1,214 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/hashicorp/go-plugin/internal/plugin"
+	"google.golang.org/grpc"
+)
+
+// BrokerService is a gRPC server served over the GRPCBroker under a name,
+// returned by GRPCBroker.ServeService. The other side connects to it with
+// GRPCBroker.DialService, without having to pass a broker ID around.
+//
+// The service is served until it is closed or the broker is closed, which
+// happens when the plugin client is closed.
+type BrokerService struct {
+	name   string
+	id     uint32
+	broker *GRPCBroker
+	server *grpc.Server
+	ln     net.Listener
+
+	doneCh chan struct{}
+	once   sync.Once
+}
+
+// Name returns the name the service is served under.
+func (s *BrokerService) Name() string {
+	return s.name
+}
+
+// ID returns the broker ID the service was allocated.
+func (s *BrokerService) ID() uint32 {
+	return s.id
+}
+
+// Close stops serving the service and tells the other side it is gone.
+// Connections already made to the service are closed.
+func (s *BrokerService) Close() error {
+	s.once.Do(func() {
+		close(s.doneCh)
+
+		b := s.broker
+		b.Lock()
+		if b.localServices[s.name] == s {
+			delete(b.localServices, s.name)
+		}
+		b.Unlock()
+
+		select {
+		case <-b.doneCh:
+		default:
+			// Best effort, the other side forgets the service anyways when
+			// the broker is closed.
+			_ = b.streamer.Send(&plugin.ConnInfo{
+				ServiceId: s.id,
+				Service:   &plugin.ConnInfo_Service{Name: s.name, Removed: true},
+			})
+		}
+
+		s.server.Stop()
+		_ = s.ln.Close()
+	})
+
+	return nil
+}
+
+// ServeService serves a new gRPC server on the broker under the given name.
+// register is called to register the implementations on the server before
+// the service is announced to the other side. Only one service can be
+// served under a name at a time by each side.
+//
+// The returned BrokerService must be closed when it is no longer needed,
+// for example when the call it was served for returns. WithService does
+// this automatically.
+func (b *GRPCBroker) ServeService(name string, register func(*grpc.Server)) (*BrokerService, error) {
+	if name == "" {
+		return nil, errors.New("service name must not be empty")
+	}
+
+	s := &BrokerService{
+		name:   name,
+		id:     b.NextId(),
+		broker: b,
+		doneCh: make(chan struct{}),
+	}
+
+	b.Lock()
+	if _, ok := b.localServices[name]; ok {
+		b.Unlock()
+		return nil, fmt.Errorf("service %q is already being served", name)
+	}
+	b.localServices[name] = s
+	b.Unlock()
+
+	info := &plugin.ConnInfo{
+		ServiceId: s.id,
+		Service:   &plugin.ConnInfo_Service{Name: name},
+	}
+
+	var err error
+	if b.muxer.Enabled() {
+		s.ln, err = b.Accept(s.id)
+	} else {
+		s.ln, info.Network, info.Address, err = b.listen()
+		if err == nil {
+			s.ln = b.registry.trackListener(s.id, s.ln)
+		}
+	}
+	if err != nil {
+		b.Lock()
+		delete(b.localServices, name)
+		b.Unlock()
+		return nil, err
+	}
+
+	s.server = grpc.NewServer(b.serverOptions()...)
+	register(s.server)
+	go func() { _ = s.server.Serve(s.ln) }()
+
+	if err := b.streamer.Send(info); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+
+	// The service doesn't outlive the broker.
+	go func() {
+		select {
+		case <-b.doneCh:
+			_ = s.Close()
+		case <-s.doneCh:
+		}
+	}()
+
+	return s, nil
+}
+
+// WithService serves a service under the given name for the duration of f.
+// It is typically used to pass a callback to the other side for a single
+// call:
+//
+//	err := broker.WithService("kv.add", func(s *grpc.Server) {
+//		proto.RegisterAddHelperServer(s, helper)
+//	}, func() error {
+//		_, err := client.Put(ctx, req)
+//		return err
+//	})
+func (b *GRPCBroker) WithService(name string, register func(*grpc.Server), f func() error) error {
+	s, err := b.ServeService(name, register)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	return f()
+}
+
+// DialService connects to the service served by the other side under the
+// given name. If the service hasn't been announced yet, DialService waits
+// for it until ctx is done.
+//
+// The returned connection should be closed when it is no longer needed. It
+// stops working once the other side closes the service.
+func (b *GRPCBroker) DialService(ctx context.Context, name string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	var info *plugin.ConnInfo
+	for {
+		b.Lock()
+		info = b.services[name]
+		ch := b.servicesCh
+		b.Unlock()
+
+		if info != nil {
+			break
+		}
+
+		select {
+		case <-ch:
+		case <-b.doneCh:
+			return nil, errors.New("broker closed")
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting for service %q: %w", name, ctx.Err())
+		}
+	}
+
+	if b.muxer.Enabled() {
+		return b.muxDialGRPC(info.ServiceId, opts...)
+	}
+	return b.dialConnInfo(info, opts...)
+}
+
+// updateService records a service announced or removed by the other side.
+func (b *GRPCBroker) updateService(msg *plugin.ConnInfo) {
+	b.Lock()
+	defer b.Unlock()
+
+	name := msg.Service.Name
+	if msg.Service.Removed {
+		if current, ok := b.services[name]; ok && current.ServiceId == msg.ServiceId {
+			delete(b.services, name)
+		}
+	} else {
+		b.services[name] = msg
+	}
+
+	close(b.servicesCh)
+	b.servicesCh = make(chan struct{})
+}
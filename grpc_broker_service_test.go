@@ -0,0 +1,101 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	grpctest "github.com/hashicorp/go-plugin/test/grpc"
+	"google.golang.org/grpc"
+)
+
+func TestGRPCBroker_service(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		testGRPCBrokerService(t, false)
+	})
+	t.Run("mux", func(t *testing.T) {
+		testGRPCBrokerService(t, true)
+	})
+}
+
+func testGRPCBrokerService(t *testing.T, multiplex bool) {
+	client, server := TestPluginGRPCConn(t, multiplex, map[string]Plugin{
+		"test": new(testGRPCInterfacePlugin),
+	})
+	defer func() { _ = client.Close() }()
+	defer server.Stop()
+
+	hostBroker, pluginBroker := client.broker, server.broker
+	register := func(s *grpc.Server) {
+		grpctest.RegisterPingPongServer(s, &pingPongServer{})
+	}
+	ping := func(ctx context.Context) error {
+		conn, err := pluginBroker.DialService(ctx, "pingpong")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = conn.Close() }()
+
+		resp, err := grpctest.NewPingPongClient(conn).Ping(ctx, &grpctest.PingRequest{})
+		if err != nil {
+			return err
+		}
+		if resp.Msg != "pong" {
+			t.Fatalf("bad: %#v", resp)
+		}
+		return nil
+	}
+
+	// Dialing waits for the service to be served.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- ping(ctx) }()
+
+	svc, err := hostBroker.ServeService("pingpong", register)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A name can only be served once at a time.
+	if _, err := hostBroker.ServeService("pingpong", register); err == nil {
+		t.Fatal("should error")
+	}
+
+	// Once closed, the other side forgets the service.
+	if err := svc.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pluginBroker.Lock()
+		_, ok := pluginBroker.services["pingpong"]
+		pluginBroker.Unlock()
+		if !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("service not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer shortCancel()
+	if _, err := pluginBroker.DialService(shortCtx, "pingpong"); err == nil {
+		t.Fatal("should error")
+	}
+
+	// WithService serves the service for the duration of the call.
+	err = hostBroker.WithService("pingpong", register, func() error {
+		return ping(ctx)
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestGRPCBroker_concurrentMux(t *testing.T) {
+	client, server := TestPluginGRPCConn(t, true, map[string]Plugin{
+		"test": new(testGRPCInterfacePlugin),
+	})
+	defer func() { _ = client.Close() }()
+	defer server.Stop()
+
+	t.Run("host serves", func(t *testing.T) {
+		testGRPCBrokerConcurrent(t, client.broker, server.broker)
+	})
+	t.Run("plugin serves", func(t *testing.T) {
+		testGRPCBrokerConcurrent(t, server.broker, client.broker)
+	})
+}
+
+// testGRPCBrokerConcurrent serves many brokered servers concurrently from
+// one side and dials them concurrently from the other, checking that each
+// connection reaches the server for its ID.
+func testGRPCBrokerConcurrent(t *testing.T, acceptor, dialer *GRPCBroker) {
+	const n = 20
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, n)
+	for i := 0; i < n; i++ {
+		id := acceptor.NextId()
+		name := fmt.Sprint(id)
+
+		go acceptor.AcceptAndServe(id, func(opts []grpc.ServerOption) *grpc.Server {
+			s := grpc.NewServer(opts...)
+			// Each server only knows about its own ID.
+			h := health.NewServer()
+			h.SetServingStatus(name, healthpb.HealthCheckResponse_SERVING)
+			healthpb.RegisterHealthServer(s, h)
+			return s
+		})
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			conn, err := dialer.Dial(id)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			defer func() { _ = conn.Close() }()
+
+			resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{
+				Service: name,
+			})
+			if err != nil {
+				errCh <- fmt.Errorf("id %d: %w", id, err)
+				return
+			}
+			if resp.Status != healthpb.HealthCheckResponse_SERVING {
+				errCh <- fmt.Errorf("id %d: bad status %s", id, resp.Status)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		t.Error(err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"math"
 	"net"
 
+	"github.com/hashicorp/go-plugin/internal/grpcmux"
 	"github.com/hashicorp/go-plugin/internal/plugin"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -40,6 +41,9 @@ func dialGRPCConn(tls *tls.Config, dialer func(context.Context, string) (net.Con
 		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(math.MaxInt32)),
 		grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(math.MaxInt32)))
 
+	// Decode the errors encoded by the other side's servers.
+	opts = append(opts, grpcErrorDialOptions()...)
+
 	// Add our custom options if we have any
 	opts = append(opts, dialOpts...)
 
@@ -53,10 +57,35 @@ func dialGRPCConn(tls *tls.Config, dialer func(context.Context, string) (net.Con
 	return conn, nil
 }
 
+// newGRPCClientProtocol is the ClientProtocolFactory for ProtocolGRPC.
+func newGRPCClientProtocol(cfg *ClientProtocolConfig) (ClientProtocol, error) {
+	client, err := newGRPCClient(cfg.DoneCtx, cfg.client)
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
 // newGRPCClient creates a new GRPCClient. The Client argument is expected
 // to be successfully started already with a lock held.
 func newGRPCClient(doneCtx context.Context, c *Client) (*GRPCClient, error) {
-	conn, err := dialGRPCConn(c.config.TLSConfig, c.dialer, c.config.GRPCDialOptions...)
+	// Calls failing because the plugin exited return a PluginExitedError.
+	exits := newExitChecker(doneCtx)
+	dialOpts := exitedDialOptions(exits.check)
+	if c.idle != nil {
+		dialOpts = append(dialOpts, idleDialOptions(c.idle)...)
+	}
+	dialOpts = append(dialOpts, c.config.GRPCDialOptions...)
+
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		conn, err := c.dialer(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		return exits.wrapConn(conn), nil
+	}
+	conn, err := dialGRPCConn(c.config.TLSConfig, dialer, dialOpts...)
 	if err != nil {
 		return nil, err
 	}
@@ -68,7 +97,7 @@ func newGRPCClient(doneCtx context.Context, c *Client) (*GRPCClient, error) {
 
 	// Start the broker.
 	brokerGRPCClient := newGRPCBrokerClient(conn)
-	broker := newGRPCBroker(brokerGRPCClient, c.config.TLSConfig, c.unixSocketCfg, c.runner, muxer)
+	broker := newGRPCBroker(brokerGRPCClient, c.config.TLSConfig, c.unixSocketCfg, c.runner, muxer, c.config.Broker)
 	go broker.Run()
 	go func() { _ = brokerGRPCClient.StartStream() }()
 
@@ -84,7 +113,10 @@ func newGRPCClient(doneCtx context.Context, c *Client) (*GRPCClient, error) {
 		Plugins:    c.config.Plugins,
 		doneCtx:    doneCtx,
 		broker:     broker,
+		muxer:      muxer,
 		controller: plugin.NewGRPCControllerClient(conn),
+		exits:      exits,
+		idle:       c.idle,
 	}
 
 	return cl, nil
@@ -97,30 +129,59 @@ type GRPCClient struct {
 
 	doneCtx context.Context
 	broker  *GRPCBroker
+	muxer   *grpcmux.GRPCClientMuxer
 
 	controller plugin.GRPCControllerClient
+
+	// exits classifies the errors of calls to the plugin, and idle tracks
+	// them if it is not nil. gRPC calls are handled by interceptors, these
+	// are for the net/rpc calls of bridged plugins.
+	exits *exitChecker
+	idle  *idleTracker
 }
 
 // ClientProtocol impl.
 func (c *GRPCClient) Close() error {
 	_ = c.broker.Close()
 	_, _ = c.controller.Shutdown(c.doneCtx, &plugin.Empty{})
-	return c.Conn.Close()
+	err := c.Conn.Close()
+
+	// Close the multiplexed connection too, so that plugins which keep
+	// running, such as in debug mode, can accept a new host.
+	if c.muxer.Enabled() {
+		_ = c.muxer.Close()
+	}
+	return err
 }
 
 // ClientProtocol impl.
 func (c *GRPCClient) Dispense(name string) (interface{}, error) {
+	return c.DispenseContext(context.Background(), name)
+}
+
+// DispenseContext is Dispense, but the context passed to the plugin's
+// GRPCClient also carries the values of ctx, such as trace spans. It is
+// still only cancelled once the plugin exits.
+func (c *GRPCClient) DispenseContext(ctx context.Context, name string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	raw, ok := c.Plugins[name]
 	if !ok {
 		return nil, fmt.Errorf("unknown plugin type: %s", name)
 	}
 
+	if p, ok := raw.(netRPCBridgedPlugin); ok {
+		return c.dispenseNetRPCBridge(name, p.netRPCPlugin())
+	}
+
 	p, ok := raw.(GRPCPlugin)
 	if !ok {
 		return nil, fmt.Errorf("plugin %q doesn't support gRPC", name)
 	}
 
-	return p.GRPCClient(c.doneCtx, c.broker, c.Conn)
+	return p.GRPCClient(withValues(c.doneCtx, ctx), c.broker, c.Conn)
 }
 
 // ClientProtocol impl.
@@ -20,6 +20,11 @@ type grpcControllerServer struct {
 func (s *grpcControllerServer) Shutdown(ctx context.Context, _ *plugin.Empty) (*plugin.Empty, error) {
 	resp := &plugin.Empty{}
 
+	// In debug mode the server outlives its clients.
+	if s.server.persistent {
+		return resp, nil
+	}
+
 	// TODO: figure out why GracefullStop doesn't work.
 	s.server.Stop()
 	return resp, nil
@@ -0,0 +1,261 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+
+	"github.com/hashicorp/yamux"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// NetRPCBridgePlugin runs a net/rpc Plugin over ProtocolGRPC, unchanged.
+// This lets plugins that haven't been ported to GRPCPlugin yet use the gRPC
+// transport, and its stdio forwarding, health checking and interceptors,
+// alongside plugins that have.
+//
+// Each dispensed plugin gets a gRPC stream carrying its net/rpc connection.
+// The MuxBroker given to the plugin brokers connections within that stream.
+//
+// NetRPCBridgePlugin must be used on both the host and the plugin side. It
+// also works over ProtocolNetRPC, where the Plugin is used directly.
+type NetRPCBridgePlugin struct {
+	Plugin
+}
+
+func (p *NetRPCBridgePlugin) netRPCPlugin() Plugin {
+	return p.Plugin
+}
+
+// netRPCBridgedPlugin is implemented by NetRPCBridgePlugin. GRPCServer and
+// GRPCClient serve and dispense these through the bridge service instead of
+// as a GRPCPlugin.
+type netRPCBridgedPlugin interface {
+	netRPCPlugin() Plugin
+}
+
+const (
+	netRPCBridgeServiceName = "plugin.GRPCNetRPCBridge"
+	netRPCBridgeMethod      = "/" + netRPCBridgeServiceName + "/Stream"
+
+	// netRPCBridgePluginKey is the metadata key holding the name of the
+	// plugin to dispense on a bridge stream.
+	netRPCBridgePluginKey = "plugin-name"
+
+	// netRPCBridgeReadyKey is sent in the stream header once the plugin has
+	// been created, so that the host can report errors from Dispense.
+	netRPCBridgeReadyKey = "plugin-ready"
+
+	// netRPCBridgeConnID is the MuxBroker ID of the plugin's net/rpc
+	// connection. NextId never returns it.
+	netRPCBridgeConnID = 0
+)
+
+var netRPCBridgeStreamDesc = grpc.StreamDesc{
+	StreamName:    "Stream",
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+// netRPCBridgeServer serves bridged plugins on a gRPC server.
+type netRPCBridgeServer struct {
+	plugins      map[string]Plugin
+	brokerConfig *BrokerConfig
+}
+
+func registerNetRPCBridgeServer(s *grpc.Server, srv *netRPCBridgeServer) {
+	desc := netRPCBridgeStreamDesc
+	desc.Handler = func(_ interface{}, stream grpc.ServerStream) error {
+		return srv.stream(stream)
+	}
+
+	s.RegisterService(&grpc.ServiceDesc{
+		ServiceName: netRPCBridgeServiceName,
+		HandlerType: (*interface{})(nil),
+		Streams:     []grpc.StreamDesc{desc},
+	}, srv)
+}
+
+// stream serves a single dispensed plugin until the host hangs up.
+func (s *netRPCBridgeServer) stream(stream grpc.ServerStream) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	var name string
+	if v := md.Get(netRPCBridgePluginKey); len(v) > 0 {
+		name = v[0]
+	}
+	p, ok := s.plugins[name]
+	if !ok {
+		return status.Errorf(codes.NotFound, "unknown plugin type: %s", name)
+	}
+
+	conn := newNetRPCBridgeConn(stream, nil)
+	mux, err := yamux.Server(conn, nil)
+	if err != nil {
+		return status.Errorf(codes.Internal, "error creating yamux server: %s", err)
+	}
+	defer func() { _ = mux.Close() }()
+
+	broker := newMuxBroker(mux, s.brokerConfig)
+	go broker.Run()
+
+	impl, err := p.Server(broker)
+	if err != nil {
+		return status.Error(codes.Unknown, err.Error())
+	}
+
+	if err := stream.SendHeader(metadata.Pairs(netRPCBridgeReadyKey, "true")); err != nil {
+		return err
+	}
+
+	rpcConn, err := broker.Accept(netRPCBridgeConnID)
+	if err != nil {
+		log.Printf("[ERR] go-plugin: plugin dispense error: %s: %s", name, err)
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	serve(rpcConn, "Plugin", impl)
+	return nil
+}
+
+// dispenseNetRPCBridge dispenses a bridged plugin over the client's
+// connection. The calls of the plugin's net/rpc client are classified and
+// tracked like those of an RPCClient, as the bridge stream itself isn't.
+func (c *GRPCClient) dispenseNetRPCBridge(name string, p Plugin) (interface{}, error) {
+	ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(c.doneCtx, netRPCBridgePluginKey, name))
+	stream, err := c.Conn.NewStream(ctx, &netRPCBridgeStreamDesc, netRPCBridgeMethod)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// Wait for the plugin to be created. If that failed, the header is
+	// empty and the error is the stream's status.
+	header, err := stream.Header()
+	if err == nil && len(header.Get(netRPCBridgeReadyKey)) == 0 {
+		err = stream.RecvMsg(new(wrapperspb.BytesValue))
+		if err == nil || err == io.EOF {
+			err = fmt.Errorf("plugin %q bridge closed unexpectedly", name)
+		}
+	}
+	if err != nil {
+		cancel()
+		if s, ok := status.FromError(err); ok {
+			return nil, fmt.Errorf("%s", s.Message())
+		}
+		return nil, err
+	}
+
+	bridgeConn := newNetRPCBridgeConn(stream, func() error {
+		cancel()
+		return nil
+	})
+	mux, err := yamux.Client(bridgeConn, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	broker := newMuxBroker(mux, c.broker.registry.config)
+	go broker.Run()
+
+	rpcConn, err := broker.Dial(netRPCBridgeConnID)
+	if err != nil {
+		_ = mux.Close()
+		return nil, err
+	}
+
+	// Closing the plugin's rpc.Client closes the whole stream.
+	rpcConn = &netRPCBridgeClientConn{Conn: rpcConn, mux: mux}
+	return p.Client(broker, newPluginRPCClient(rpcConn, c.exits.check, c.idle))
+}
+
+// netRPCBridgeClientConn closes the bridge session along with the plugin's
+// net/rpc connection.
+type netRPCBridgeClientConn struct {
+	net.Conn
+	mux *yamux.Session
+}
+
+func (c *netRPCBridgeClientConn) Close() error {
+	err := c.Conn.Close()
+	_ = c.mux.Close()
+	return err
+}
+
+// netRPCBridgeStream is implemented by both grpc.ClientStream and
+// grpc.ServerStream.
+type netRPCBridgeStream interface {
+	SendMsg(m interface{}) error
+	RecvMsg(m interface{}) error
+}
+
+// netRPCBridgeConn adapts a gRPC stream of bytes to an io.ReadWriteCloser.
+type netRPCBridgeConn struct {
+	stream  netRPCBridgeStream
+	closeFn func() error
+
+	buf []byte
+
+	writeLock sync.Mutex
+}
+
+func newNetRPCBridgeConn(stream netRPCBridgeStream, closeFn func() error) *netRPCBridgeConn {
+	return &netRPCBridgeConn{
+		stream:  stream,
+		closeFn: closeFn,
+	}
+}
+
+func (c *netRPCBridgeConn) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		msg := new(wrapperspb.BytesValue)
+		if err := c.stream.RecvMsg(msg); err != nil {
+			// The stream ending because either side went away is the
+			// equivalent of a closed connection.
+			switch status.Code(err) {
+			case codes.Canceled, codes.Unavailable:
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		c.buf = msg.Value
+	}
+
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+func (c *netRPCBridgeConn) Write(p []byte) (int, error) {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	// gRPC may hold on to the message after SendMsg returns, so it needs its
+	// own copy of the data.
+	data := make([]byte, len(p))
+	copy(data, p)
+	if err := c.stream.SendMsg(wrapperspb.Bytes(data)); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close ends the stream on the host side. On the plugin side the stream ends
+// when the handler returns.
+func (c *netRPCBridgeConn) Close() error {
+	if c.closeFn == nil {
+		return nil
+	}
+	return c.closeFn()
+}
@@ -0,0 +1,126 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"net/rpc"
+	"strings"
+	"testing"
+)
+
+var testNetRPCBridgePluginMap = map[string]Plugin{
+	"test":     &NetRPCBridgePlugin{Plugin: new(testInterfacePlugin)},
+	"callback": &NetRPCBridgePlugin{Plugin: new(testCallbackPlugin)},
+}
+
+// testCallbackPlugin calls back into the host over the MuxBroker.
+type testCallbackPlugin struct{}
+
+func (p *testCallbackPlugin) Server(b *MuxBroker) (interface{}, error) {
+	return &testCallbackServer{broker: b}, nil
+}
+
+func (p *testCallbackPlugin) Client(b *MuxBroker, c *rpc.Client) (interface{}, error) {
+	return &testCallbackClient{broker: b, client: c}, nil
+}
+
+type testCallbackClient struct {
+	broker *MuxBroker
+	client *rpc.Client
+}
+
+// Add asks the plugin to add a and b using the host's adder.
+func (c *testCallbackClient) Add(a, b int) (int, error) {
+	id := c.broker.NextId()
+	go c.broker.AcceptAndServe(id, new(testAdder))
+
+	var resp int
+	err := c.client.Call("Plugin.Add", [3]int{int(id), a, b}, &resp)
+	return resp, err
+}
+
+type testCallbackServer struct {
+	broker *MuxBroker
+}
+
+// Add takes the broker ID of the host's adder, and the numbers to add.
+func (s *testCallbackServer) Add(args [3]int, resp *int) error {
+	conn, err := s.broker.Dial(uint32(args[0]))
+	if err != nil {
+		return err
+	}
+	client := rpc.NewClient(conn)
+	defer client.Close()
+
+	return client.Call("Plugin.Add", [2]int{args[1], args[2]}, resp)
+}
+
+type testAdder struct{}
+
+func (a *testAdder) Add(args [2]int, resp *int) error {
+	*resp = args[0] + args[1]
+	return nil
+}
+
+func TestClient_netRPCBridge(t *testing.T) {
+	process := helperProcess("test-netrpc-bridge")
+	c := NewClient(&ClientConfig{
+		Cmd:              process,
+		HandshakeConfig:  testHandshake,
+		Plugins:          testNetRPCBridgePluginMap,
+		AllowedProtocols: []Protocol{ProtocolGRPC},
+	})
+	defer c.Kill()
+
+	client, err := c.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The plugin types alone select gRPC when a gRPC server is configured.
+	if v := c.Protocol(); v != ProtocolGRPC {
+		t.Fatalf("bad: %s", v)
+	}
+
+	raw, err := client.Dispense("test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n := raw.(testInterface).Double(21); n != 42 {
+		t.Fatalf("bad: %d", n)
+	}
+
+	// The MuxBroker works within the bridge
+	raw, err = client.Dispense("callback")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	n, err := raw.(*testCallbackClient).Add(2, 3)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n != 5 {
+		t.Fatalf("bad: %d", n)
+	}
+
+	// Closing the rpc.Client ends the bridge stream
+	if err := raw.(*testCallbackClient).client.Close(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := raw.(*testCallbackClient).Add(1, 1); err == nil {
+		t.Fatal("expected error after close")
+	}
+
+	// Unknown plugins are reported by Dispense
+	c.config.Plugins["missing"] = &NetRPCBridgePlugin{Plugin: new(testInterfacePlugin)}
+	defer delete(c.config.Plugins, "missing")
+	if _, err := client.Dispense("missing"); err == nil || !strings.Contains(err.Error(), "unknown plugin type") {
+		t.Fatalf("expected unknown plugin error, got: %v", err)
+	}
+
+	c.Kill()
+	if c.killed() {
+		t.Fatal("process failed to exit gracefully")
+	}
+}
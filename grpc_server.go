@@ -10,6 +10,8 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"os"
+	"strconv"
 
 	hclog "github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-plugin/internal/grpcmux"
@@ -64,6 +66,58 @@ type GRPCServer struct {
 	logger hclog.Logger
 
 	muxer *grpcmux.GRPCServerMuxer
+
+	// persistent is set in debug mode, where the server keeps running when
+	// a client asks it to shut down, and the broker must outlive each
+	// client's broker stream.
+	persistent bool
+
+	// brokerConfig configures the GRPCBroker, and the MuxBroker of bridged
+	// net/rpc plugins.
+	brokerConfig *BrokerConfig
+}
+
+// newGRPCServerProtocol is the ServerProtocolFactory for ProtocolGRPC.
+func newGRPCServerProtocol(cfg *ServerProtocolConfig) (ServerProtocol, error) {
+	server := DefaultGRPCServer
+	if cfg.ServeConfig != nil && cfg.ServeConfig.GRPCServer != nil {
+		server = cfg.ServeConfig.GRPCServer
+	}
+
+	multiplex, _ := strconv.ParseBool(os.Getenv(envMultiplexGRPC))
+	streamIDs, _ := strconv.ParseBool(os.Getenv(envMultiplexGRPCStreamIDs))
+	if sc := cfg.ServeConfig; sc != nil {
+		// Hosts reattaching in test and debug mode can't ask for multiplexing
+		// in the environment, but are told about it in the reattach config.
+		// They always support stream IDs.
+		if (sc.Test != nil && sc.Test.GRPCBrokerMultiplex) || (sc.Debug != nil && sc.Debug.GRPCBrokerMultiplex) {
+			multiplex, streamIDs = true, true
+		}
+	}
+
+	var muxer *grpcmux.GRPCServerMuxer
+	if multiplex {
+		muxer = grpcmux.NewGRPCServerMuxer(cfg.Logger, cfg.Listener, streamIDs)
+		cfg.Listener = muxer
+	}
+
+	s := &GRPCServer{
+		Plugins: cfg.Plugins,
+		Server:  server,
+		TLS:     cfg.TLS,
+		Stdout:  cfg.Stdout,
+		Stderr:  cfg.Stderr,
+		DoneCh:  cfg.DoneCh,
+		logger:  cfg.Logger,
+		muxer:   muxer,
+
+		persistent: cfg.ServeConfig != nil && cfg.ServeConfig.Debug != nil,
+	}
+	if cfg.ServeConfig != nil {
+		s.brokerConfig = cfg.ServeConfig.Broker
+	}
+
+	return s, nil
 }
 
 // ServerProtocol impl.
@@ -73,6 +127,7 @@ func (s *GRPCServer) Init() error {
 	if s.TLS != nil {
 		opts = append(opts, grpc.Creds(credentials.NewTLS(s.TLS)))
 	}
+	opts = append(opts, grpcErrorServerOptions()...)
 	s.server = s.Server(opts)
 
 	// Register the health service
@@ -86,8 +141,10 @@ func (s *GRPCServer) Init() error {
 
 	// Register the broker service
 	brokerServer := newGRPCBrokerServer()
+	brokerServer.persistent = s.persistent
 	plugin.RegisterGRPCBrokerServer(s.server, brokerServer)
-	s.broker = newGRPCBroker(brokerServer, s.TLS, unixSocketConfigFromEnv(), nil, s.muxer)
+	s.broker = newGRPCBroker(brokerServer, s.TLS, unixSocketConfigFromEnv(), nil, s.muxer, s.brokerConfig)
+	brokerServer.endSession = s.broker.resetSession
 	go s.broker.Run()
 
 	// Register the controller
@@ -98,8 +155,15 @@ func (s *GRPCServer) Init() error {
 	s.stdioServer = newGRPCStdioServer(s.logger, s.Stdout, s.Stderr)
 	plugin.RegisterGRPCStdioServer(s.server, s.stdioServer)
 
-	// Register all our plugins onto the gRPC server.
+	// Register all our plugins onto the gRPC server. Bridged net/rpc plugins
+	// are all served by the bridge service.
+	bridged := make(map[string]Plugin)
 	for k, raw := range s.Plugins {
+		if p, ok := raw.(netRPCBridgedPlugin); ok {
+			bridged[k] = p.netRPCPlugin()
+			continue
+		}
+
 		p, ok := raw.(GRPCPlugin)
 		if !ok {
 			return fmt.Errorf("%q is not a GRPC-compatible plugin", k)
@@ -109,6 +173,9 @@ func (s *GRPCServer) Init() error {
 			return fmt.Errorf("error registering %q: %s", k, err)
 		}
 	}
+	if len(bridged) > 0 {
+		registerNetRPCBridgeServer(s.server, &netRPCBridgeServer{plugins: bridged, brokerConfig: s.brokerConfig})
+	}
 
 	return nil
 }
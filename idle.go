@@ -0,0 +1,330 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"io"
+	"net/rpc"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// idleTracker counts the calls in flight to a plugin, and calls onIdle once
+// there were none for the idle timeout. onIdle is passed the generation of
+// the tracker, which changes on every call and every touch, so it can check
+// that the plugin is still idle with stillIdle.
+type idleTracker struct {
+	timeout time.Duration
+	onIdle  func(gen uint64)
+
+	lock     sync.Mutex
+	inflight int
+	gen      uint64
+	timer    *time.Timer
+	closed   bool
+}
+
+func newIdleTracker(timeout time.Duration, onIdle func(gen uint64)) *idleTracker {
+	return &idleTracker{timeout: timeout, onIdle: onIdle}
+}
+
+// begin records the start of a call.
+func (t *idleTracker) begin() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.inflight++
+	t.gen++
+	t.stopTimer()
+}
+
+// end records the end of a call.
+func (t *idleTracker) end() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.inflight--
+	t.gen++
+	t.resetTimer()
+}
+
+// touch records a use of the plugin other than a call, restarting the idle
+// timeout if no call is in flight.
+func (t *idleTracker) touch() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.gen++
+	t.resetTimer()
+}
+
+// stillIdle returns true if the plugin wasn't used since generation gen.
+func (t *idleTracker) stillIdle(gen uint64) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return !t.closed && t.inflight == 0 && t.gen == gen
+}
+
+// close stops the tracker for good.
+func (t *idleTracker) close() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.closed = true
+	t.stopTimer()
+}
+
+// resetTimer restarts the idle timeout if no call is in flight. It must be
+// called with the tracker locked.
+func (t *idleTracker) resetTimer() {
+	t.stopTimer()
+	if t.closed || t.inflight > 0 {
+		return
+	}
+	gen := t.gen
+	t.timer = time.AfterFunc(t.timeout, func() { t.onIdle(gen) })
+}
+
+// stopTimer must be called with the tracker locked.
+func (t *idleTracker) stopTimer() {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+// stopIdle kills the plugin if it is still idle since generation gen, and
+// resets the client so that the plugin is started again on its next use.
+func (c *Client) stopIdle(gen uint64) {
+	c.restartLock.Lock()
+	defer c.restartLock.Unlock()
+
+	if !c.idle.stillIdle(gen) {
+		return
+	}
+
+	c.l.Lock()
+	started := c.address != nil
+	reattached := c.config.Reattach != nil
+	c.l.Unlock()
+	if !started || reattached {
+		return
+	}
+
+	c.logger.Debug("stopping idle plugin", "idle_stop_timeout", c.config.IdleStopTimeout)
+	c.kill(context.Background())
+	c.reset()
+}
+
+// reset clears the state of a killed client, so that it can be started
+// again.
+func (c *Client) reset() {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	c.address = nil
+	c.client = nil
+	c.exited = false
+	c.exitErr = nil
+	c.processKilled = false
+	c.protocol = ProtocolInvalid
+	c.negotiatedVersion = 0
+	c.unixSocketCfg = UnixSocketConfig{}
+	c.grpcMuxerOnce = sync.Once{}
+	c.grpcMuxer = nil
+	c.grpcMux = false
+	c.grpcMuxStreamIDs = false
+	c.autoMTLS = nil
+
+	// A command can only be started once.
+	if c.cmdTemplate != nil {
+		c.config.Cmd = cloneCmd(c.cmdTemplate)
+	}
+}
+
+// cloneCmd returns an unstarted copy of cmd. The context of commands
+// created with exec.CommandContext isn't copied.
+func cloneCmd(cmd *exec.Cmd) *exec.Cmd {
+	return &exec.Cmd{
+		Path:        cmd.Path,
+		Args:        append([]string(nil), cmd.Args...),
+		Env:         append([]string(nil), cmd.Env...),
+		Dir:         cmd.Dir,
+		Stdin:       cmd.Stdin,
+		Stdout:      cmd.Stdout,
+		Stderr:      cmd.Stderr,
+		ExtraFiles:  cmd.ExtraFiles,
+		SysProcAttr: cmd.SysProcAttr,
+		WaitDelay:   cmd.WaitDelay,
+	}
+}
+
+// isInternalMethod returns true for the gRPC methods of the services used
+// by go-plugin itself, which don't count as uses of the plugin.
+func isInternalMethod(method string) bool {
+	return strings.HasPrefix(method, "/plugin.") || strings.HasPrefix(method, "/grpc.health.")
+}
+
+// idleDialOptions returns the interceptors tracking the gRPC calls to the
+// plugin with t.
+func idleDialOptions(t *idleTracker) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			if isInternalMethod(method) {
+				return invoker(ctx, method, req, reply, cc, opts...)
+			}
+			t.begin()
+			defer t.end()
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}),
+		grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			if isInternalMethod(method) {
+				return streamer(ctx, desc, cc, method, opts...)
+			}
+			t.begin()
+			s, err := streamer(ctx, desc, cc, method, opts...)
+			if err != nil {
+				t.end()
+				return nil, err
+			}
+
+			is := &idleClientStream{ClientStream: s, desc: desc, tracker: t, done: make(chan struct{})}
+			go func() {
+				// Streams abandoned by cancelling their context end too.
+				select {
+				case <-ctx.Done():
+					is.end()
+				case <-is.done:
+				}
+			}()
+			return is, nil
+		}),
+	}
+}
+
+// idleClientStream ends the call it was started for once the stream ends.
+type idleClientStream struct {
+	grpc.ClientStream
+	desc    *grpc.StreamDesc
+	tracker *idleTracker
+	once    sync.Once
+	done    chan struct{}
+}
+
+func (s *idleClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.desc.ServerStreams {
+		s.end()
+	}
+	return err
+}
+
+func (s *idleClientStream) end() {
+	s.once.Do(func() {
+		close(s.done)
+		s.tracker.end()
+	})
+}
+
+// idleClientCodec is the gob codec of net/rpc clients, tracking the calls
+// in flight with a tracker.
+type idleClientCodec struct {
+	rwc     io.ReadWriteCloser
+	dec     *gob.Decoder
+	enc     *gob.Encoder
+	encBuf  *bufio.Writer
+	tracker *idleTracker
+
+	lock    sync.Mutex
+	pending int
+}
+
+func newIdleClientCodec(conn io.ReadWriteCloser, t *idleTracker) *idleClientCodec {
+	encBuf := bufio.NewWriter(conn)
+	return &idleClientCodec{
+		rwc:     conn,
+		dec:     gob.NewDecoder(conn),
+		enc:     gob.NewEncoder(encBuf),
+		encBuf:  encBuf,
+		tracker: t,
+	}
+}
+
+func (c *idleClientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	c.lock.Lock()
+	c.pending++
+	c.lock.Unlock()
+	c.tracker.begin()
+
+	err := c.writeRequest(r, body)
+	if err != nil {
+		// The client fails the call right away.
+		c.endOne()
+	}
+	return err
+}
+
+func (c *idleClientCodec) writeRequest(r *rpc.Request, body interface{}) error {
+	if err := c.enc.Encode(r); err != nil {
+		return err
+	}
+	if err := c.enc.Encode(body); err != nil {
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *idleClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	if err := c.dec.Decode(r); err != nil {
+		// The client fails all the pending calls.
+		c.endAll()
+		return err
+	}
+
+	c.endOne()
+	return nil
+}
+
+func (c *idleClientCodec) ReadResponseBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *idleClientCodec) Close() error {
+	c.endAll()
+	return c.rwc.Close()
+}
+
+// endOne ends a pending call.
+func (c *idleClientCodec) endOne() {
+	c.lock.Lock()
+	ended := c.pending > 0
+	if ended {
+		c.pending--
+	}
+	c.lock.Unlock()
+
+	if ended {
+		c.tracker.end()
+	}
+}
+
+// endAll ends the calls still pending.
+func (c *idleClientCodec) endAll() {
+	c.lock.Lock()
+	pending := c.pending
+	c.pending = 0
+	c.lock.Unlock()
+
+	for i := 0; i < pending; i++ {
+		c.tracker.end()
+	}
+}
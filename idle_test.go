@@ -0,0 +1,150 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIdleTracker(t *testing.T) {
+	idle := make(chan uint64, 1)
+	tracker := newIdleTracker(50*time.Millisecond, func(gen uint64) { idle <- gen })
+	defer tracker.close()
+
+	// No timeout while a call is in flight.
+	tracker.begin()
+	select {
+	case <-idle:
+		t.Fatal("should not be idle during a call")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	tracker.end()
+	select {
+	case gen := <-idle:
+		if !tracker.stillIdle(gen) {
+			t.Fatal("should still be idle")
+		}
+		tracker.touch()
+		if tracker.stillIdle(gen) {
+			t.Fatal("should not be idle after being used")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("should be idle after the call")
+	}
+}
+
+func TestClient_IdleStopTimeout(t *testing.T) {
+	for name, tc := range testProtocols {
+		t.Run(name, func(t *testing.T) {
+			c := NewClient(&ClientConfig{
+				Cmd:              helperProcess(tc.helper),
+				HandshakeConfig:  testHandshake,
+				Plugins:          tc.plugins,
+				AllowedProtocols: []Protocol{ProtocolNetRPC, ProtocolGRPC},
+				IdleStopTimeout:  200 * time.Millisecond,
+			})
+			defer c.Kill()
+
+			// The plugin is only started when it is first used.
+			if c.status().Started {
+				t.Fatal("plugin should not be started")
+			}
+
+			dispense := func() string {
+				raw, err := c.DispenseContext(context.Background(), "test")
+				if err != nil {
+					t.Fatalf("err: %s", err)
+				}
+				if result := raw.(testInterface).Double(21); result != 42 {
+					t.Fatalf("bad: %#v", result)
+				}
+				return c.status().ID
+			}
+
+			id := dispense()
+			client, err := c.Client()
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			// The plugin is stopped once idle.
+			deadline := time.Now().Add(5 * time.Second)
+			for c.status().Started {
+				if time.Now().After(deadline) {
+					t.Fatal("plugin should have been stopped")
+				}
+				time.Sleep(50 * time.Millisecond)
+			}
+
+			// Stopping the plugin closed its protocol client, which isn't
+			// restarted by using it.
+			if err := client.Ping(); err == nil {
+				t.Fatal("the stopped plugin's client should be closed")
+			}
+			if c.status().Started {
+				t.Fatal("plugin should not be restarted by a closed client")
+			}
+
+			// It is started again by the next Dispense.
+			newID := dispense()
+			if newID == id {
+				t.Fatalf("plugin should have been restarted, got the same ID %q", newID)
+			}
+
+			// Killed plugins aren't restarted, even on their next use.
+			c.Kill()
+			_, _ = c.DispenseContext(context.Background(), "test")
+			if s := c.status(); !s.Started || !s.Exited {
+				t.Fatalf("bad: %#v", s)
+			}
+		})
+	}
+}
+
+func TestClient_IdleStopTimeout_netRPCBridge(t *testing.T) {
+	c := NewClient(&ClientConfig{
+		Cmd:              helperProcess("test-netrpc-bridge"),
+		HandshakeConfig:  testHandshake,
+		Plugins:          testNetRPCBridgePluginMap,
+		AllowedProtocols: []Protocol{ProtocolGRPC},
+		IdleStopTimeout:  200 * time.Millisecond,
+	})
+	defer c.Kill()
+
+	raw, err := c.DispenseContext(context.Background(), "callback")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	id := c.status().ID
+
+	// A bridged call outlasting the idle timeout keeps the plugin running.
+	callback := raw.(*testCallbackClient)
+	adderID := callback.broker.NextId()
+	go callback.broker.AcceptAndServe(adderID, &testSlowAdder{delay: time.Second})
+
+	var n int
+	if err := callback.client.Call("Plugin.Add", [3]int{int(adderID), 2, 3}, &n); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n != 5 {
+		t.Fatalf("bad: %d", n)
+	}
+	if s := c.status(); !s.Started || s.ID != id {
+		t.Fatalf("plugin should not have been stopped during the call: %#v", s)
+	}
+}
+
+// testSlowAdder is a testAdder taking a while to answer.
+type testSlowAdder struct {
+	delay time.Duration
+}
+
+func (a *testSlowAdder) Add(args [2]int, resp *int) error {
+	time.Sleep(a.delay)
+	*resp = args[0] + args[1]
+	return nil
+}
@@ -25,15 +25,23 @@ var _ GRPCMuxer = (*GRPCClientMuxer)(nil)
 // a knock that matches its gRPC broker stream ID. There is no default listener
 // on the client, as it is a client for the gRPC broker's control services. (See
 // GRPCServerMuxer for more details).
+//
+// If the server supports stream IDs, each connection is instead routed to the
+// listener for the stream ID it starts with.
 type GRPCClientMuxer struct {
 	logger  hclog.Logger
 	session *yamux.Session
 
 	acceptMutex     sync.Mutex
 	acceptListeners map[uint32]*blockedClientListener
+
+	// router routes connections by stream ID, if enabled.
+	router *streamRouter
 }
 
-func NewGRPCClientMuxer(logger hclog.Logger, addr net.Addr) (*GRPCClientMuxer, error) {
+// NewGRPCClientMuxer returns a muxer dialling the multiplexed connection to
+// addr. If streamIDs is true, connections start with their stream ID.
+func NewGRPCClientMuxer(logger hclog.Logger, addr net.Addr, streamIDs bool) (*GRPCClientMuxer, error) {
 	// Eagerly establish the underlying connection as early as possible.
 	logger.Debug("making new client mux initial connection", "addr", addr)
 	conn, err := net.Dial(addr.Network(), addr.String())
@@ -61,6 +69,10 @@ func NewGRPCClientMuxer(logger hclog.Logger, addr net.Addr) (*GRPCClientMuxer, e
 		session:         sess,
 		acceptListeners: make(map[uint32]*blockedClientListener),
 	}
+	if streamIDs {
+		m.router = newStreamRouter(logger, false)
+		go m.router.run(sess)
+	}
 
 	return m, nil
 }
@@ -70,6 +82,10 @@ func (m *GRPCClientMuxer) Enabled() bool {
 }
 
 func (m *GRPCClientMuxer) Listener(id uint32, doneCh <-chan struct{}) (net.Listener, error) {
+	if m.router != nil {
+		return m.router.listener(id, m.session.Addr(), doneCh), nil
+	}
+
 	ln := newBlockedClientListener(m.session, doneCh)
 
 	m.acceptMutex.Lock()
@@ -80,6 +96,13 @@ func (m *GRPCClientMuxer) Listener(id uint32, doneCh <-chan struct{}) (net.Liste
 }
 
 func (m *GRPCClientMuxer) AcceptKnock(id uint32) error {
+	if m.router != nil {
+		if !m.router.hasListener(id) {
+			return fmt.Errorf("no listener for id %d", id)
+		}
+		return nil
+	}
+
 	m.acceptMutex.Lock()
 	defer m.acceptMutex.Unlock()
 
@@ -91,15 +114,25 @@ func (m *GRPCClientMuxer) AcceptKnock(id uint32) error {
 	return nil
 }
 
-func (m *GRPCClientMuxer) Dial() (net.Conn, error) {
+func (m *GRPCClientMuxer) Dial(id uint32) (net.Conn, error) {
 	stream, err := m.session.Open()
 	if err != nil {
 		return nil, fmt.Errorf("error dialling new client stream: %w", err)
 	}
+	if m.router != nil {
+		if err := writeStreamID(stream, id); err != nil {
+			_ = stream.Close()
+			return nil, err
+		}
+	}
 
 	return stream, nil
 }
 
+func (m *GRPCClientMuxer) StreamIDs() bool {
+	return m.router != nil
+}
+
 func (m *GRPCClientMuxer) Close() error {
 	return m.session.Close()
 }
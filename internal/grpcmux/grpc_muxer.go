@@ -4,6 +4,9 @@
 package grpcmux
 
 import (
+	"encoding/binary"
+	"fmt"
+	"io"
 	"net"
 )
 
@@ -17,6 +20,12 @@ import (
 // Clients must "knock" before dialling, to tell the server side that the
 // next net.Conn should be accepted onto a specific stream ID. The knock is a
 // bidirectional streaming message on the plugin.GRPCBroker service.
+//
+// If both sides support it, each multiplexed connection also starts with
+// the stream ID it is dialled for, so that connections for different IDs
+// can be established concurrently. Otherwise connections are matched to
+// stream IDs in the order of the knocks, and must be established
+// sequentially.
 type GRPCMuxer interface {
 	// Enabled determines whether multiplexing should be used. It saves users
 	// of the interface from having to compare an interface with nil, which
@@ -31,11 +40,37 @@ type GRPCMuxer interface {
 	// error if it hasn't been created yet.
 	AcceptKnock(id uint32) error
 
-	// Dial makes a new multiplexed client connection. To dial a specific ID,
-	// a knock must be sent first.
-	Dial() (net.Conn, error)
+	// Dial makes a new multiplexed client connection for the given stream
+	// ID. To dial a specific ID, a knock must be sent first. ID 0 is the
+	// connection to the gRPC broker's control services.
+	Dial(id uint32) (net.Conn, error)
+
+	// StreamIDs returns true if multiplexed connections start with their
+	// stream ID, in which case they can be established concurrently.
+	StreamIDs() bool
 
 	// Close closes connections and releases any resources associated with the
 	// muxer.
 	Close() error
 }
+
+// writeStreamID writes the stream ID header at the start of a multiplexed
+// connection.
+func writeStreamID(conn net.Conn, id uint32) error {
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], id)
+	if _, err := conn.Write(header[:]); err != nil {
+		return fmt.Errorf("error writing stream ID: %w", err)
+	}
+	return nil
+}
+
+// readStreamID reads the stream ID header at the start of a multiplexed
+// connection.
+func readStreamID(conn net.Conn) (uint32, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return 0, fmt.Errorf("error reading stream ID: %w", err)
+	}
+	return binary.BigEndian.Uint32(header[:]), nil
+}
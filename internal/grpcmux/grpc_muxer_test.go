@@ -0,0 +1,151 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package grpcmux
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+func testMuxers(t *testing.T) (*GRPCServerMuxer, *GRPCClientMuxer) {
+	ln, err := net.Listen("unix", filepath.Join(t.TempDir(), "mux"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	logger := hclog.NewNullLogger()
+	server := NewGRPCServerMuxer(logger, ln, true)
+	client, err := NewGRPCClientMuxer(logger, ln.Addr(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	return server, client
+}
+
+func TestMuxer_streamIDs(t *testing.T) {
+	server, client := testMuxers(t)
+
+	// The control services' connection goes to the default listener.
+	if _, err := client.Dial(0); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := server.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	t.Run("client dials", func(t *testing.T) {
+		testMuxerConcurrent(t, server, client)
+	})
+	t.Run("server dials", func(t *testing.T) {
+		testMuxerConcurrent(t, client, server)
+	})
+}
+
+// testMuxerConcurrent accepts and dials many stream IDs concurrently, each
+// connection sending its ID so that the acceptor can check it got the right
+// connection.
+func testMuxerConcurrent(t *testing.T, acceptor, dialer GRPCMuxer) {
+	const n = 50
+
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	var wg sync.WaitGroup
+	for id := uint32(1); id <= n; id++ {
+		ln, err := acceptor.Listener(id, doneCh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := acceptor.AcceptKnock(id); err != nil {
+			t.Fatal(err)
+		}
+
+		wg.Add(2)
+		go func(id uint32) {
+			defer wg.Done()
+			conn, err := ln.Accept()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer func() { _ = conn.Close() }()
+
+			var payload [4]byte
+			if _, err := io.ReadFull(conn, payload[:]); err != nil {
+				t.Error(err)
+				return
+			}
+			if got := binary.BigEndian.Uint32(payload[:]); got != id {
+				t.Errorf("listener %d got connection for %d", id, got)
+			}
+		}(id)
+		go func(id uint32) {
+			defer wg.Done()
+			conn, err := dialer.Dial(id)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer func() { _ = conn.Close() }()
+
+			var payload [4]byte
+			binary.BigEndian.PutUint32(payload[:], id)
+			if _, err := conn.Write(payload[:]); err != nil {
+				t.Error(err)
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if err := acceptor.AcceptKnock(n + 1); err == nil {
+		t.Fatal("expected error knocking without a listener")
+	}
+}
+
+func TestMuxer_streamIDsListenerDone(t *testing.T) {
+	server, client := testMuxers(t)
+
+	for name, m := range map[string]GRPCMuxer{"server": server, "client": client} {
+		t.Run(name, func(t *testing.T) {
+			doneCh := make(chan struct{})
+			if _, err := m.Listener(1, doneCh); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.AcceptKnock(1); err != nil {
+				t.Fatal(err)
+			}
+
+			close(doneCh)
+			deadline := time.Now().Add(time.Second)
+			for m.AcceptKnock(1) == nil {
+				if time.Now().After(deadline) {
+					t.Fatal("expected error knocking after the listener is done")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			// The ID can be listened on again.
+			doneCh = make(chan struct{})
+			defer close(doneCh)
+			if _, err := m.Listener(1, doneCh); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.AcceptKnock(1); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
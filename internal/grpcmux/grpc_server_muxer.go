@@ -32,45 +32,105 @@ var _ net.Listener = (*GRPCServerMuxer)(nil)
 // listener, but we do need to control which gRPC server accepts which connection.
 // As such, each multiplexed listener blocks waiting on a channel. It will be
 // unblocked when a knock is received for the matching stream ID.
+//
+// If the client supports stream IDs, each connection is instead routed to
+// the listener for the stream ID it starts with, and knocks only check that
+// the listener exists.
 type GRPCServerMuxer struct {
 	addr   net.Addr
 	logger hclog.Logger
+	ln     net.Listener
+
+	// sessionCh is closed once the first session is established, or failed
+	// to be.
+	sessionCh  chan struct{}
+	sessionMu  sync.Mutex
+	sess       *yamux.Session
+	sessionErr error
 
-	sessionErrCh chan error
-	sess         *yamux.Session
+	closeCh   chan struct{}
+	closeOnce sync.Once
 
 	knockCh chan uint32
 
 	acceptMutex    sync.Mutex
 	acceptChannels map[uint32]chan acceptResult
+
+	// router routes connections by stream ID, if enabled.
+	router *streamRouter
 }
 
-func NewGRPCServerMuxer(logger hclog.Logger, ln net.Listener) *GRPCServerMuxer {
+// NewGRPCServerMuxer returns a muxer accepting the multiplexed connection on
+// ln. If streamIDs is true, connections start with their stream ID, and
+// when a session ends the muxer accepts a new one, so that hosts can
+// reattach.
+func NewGRPCServerMuxer(logger hclog.Logger, ln net.Listener, streamIDs bool) *GRPCServerMuxer {
 	m := &GRPCServerMuxer{
 		addr:   ln.Addr(),
 		logger: logger,
+		ln:     ln,
 
-		sessionErrCh: make(chan error),
+		sessionCh: make(chan struct{}),
+		closeCh:   make(chan struct{}),
 
 		knockCh:        make(chan uint32, 1),
 		acceptChannels: make(map[uint32]chan acceptResult),
 	}
+	if streamIDs {
+		m.router = newStreamRouter(logger, true)
+	}
 
 	go m.acceptSession(ln)
 
 	return m
 }
 
-// acceptSessionAndMuxAccept is responsible for establishing the yamux session,
-// and then kicking off the acceptLoop function.
+// acceptSession is responsible for establishing the yamux session. With
+// stream IDs, it also routes the session's connections, and accepts a new
+// session once it ends.
 func (m *GRPCServerMuxer) acceptSession(ln net.Listener) {
-	defer close(m.sessionErrCh)
+	first := true
+	for {
+		m.logger.Debug("accepting initial connection", "addr", m.addr)
+		sess, err := m.newSession(ln)
+
+		m.sessionMu.Lock()
+		if err == nil {
+			m.sess = sess
+		} else if first {
+			m.sessionErr = err
+		}
+		m.sessionMu.Unlock()
+
+		if first {
+			close(m.sessionCh)
+			first = false
+		}
+		if err != nil {
+			m.shutdown()
+			return
+		}
 
-	m.logger.Debug("accepting initial connection", "addr", m.addr)
+		// Without stream IDs, connections can't be told apart from those of
+		// a previous session, so only one session is supported.
+		if m.router == nil {
+			return
+		}
+
+		m.router.run(sess)
+		select {
+		case <-m.closeCh:
+			return
+		default:
+			m.logger.Debug("multiplexed session ended, waiting for a new one", "addr", m.addr)
+		}
+	}
+}
+
+func (m *GRPCServerMuxer) newSession(ln net.Listener) (*yamux.Session, error) {
 	conn, err := ln.Accept()
 	if err != nil {
-		m.sessionErrCh <- err
-		return
+		return nil, err
 	}
 
 	m.logger.Debug("initial server connection accepted", "addr", m.addr)
@@ -79,23 +139,32 @@ func (m *GRPCServerMuxer) acceptSession(ln net.Listener) {
 		InferLevels: true,
 	})
 	cfg.LogOutput = nil
-	m.sess, err = yamux.Server(conn, cfg)
-	if err != nil {
-		m.sessionErrCh <- err
-		return
-	}
+	return yamux.Server(conn, cfg)
 }
 
+// shutdown marks the muxer as closed.
+func (m *GRPCServerMuxer) shutdown() {
+	m.closeOnce.Do(func() {
+		close(m.closeCh)
+	})
+}
+
+// session returns the current session, waiting for the first one to be
+// established.
 func (m *GRPCServerMuxer) session() (*yamux.Session, error) {
 	select {
-	case err := <-m.sessionErrCh:
-		if err != nil {
-			return nil, err
-		}
+	case <-m.sessionCh:
 	case <-time.After(5 * time.Second):
 		return nil, errors.New("timed out waiting for connection to be established")
 	}
 
+	m.sessionMu.Lock()
+	defer m.sessionMu.Unlock()
+
+	if m.sessionErr != nil {
+		return nil, m.sessionErr
+	}
+
 	// Should never happen.
 	if m.sess == nil {
 		return nil, errors.New("no connection established and no error received")
@@ -106,7 +175,32 @@ func (m *GRPCServerMuxer) session() (*yamux.Session, error) {
 
 // Accept accepts all incoming connections and routes them to the correct
 // stream ID based on the most recent knock received.
+//
+// With stream IDs, it returns the connections starting with stream ID 0, and
+// waits for hosts to connect for as long as the muxer is open.
 func (m *GRPCServerMuxer) Accept() (net.Conn, error) {
+	if m.router != nil {
+		select {
+		case <-m.sessionCh:
+		case <-m.closeCh:
+			return nil, yamux.ErrSessionShutdown
+		}
+
+		m.sessionMu.Lock()
+		err := m.sessionErr
+		m.sessionMu.Unlock()
+		if err != nil {
+			return nil, fmt.Errorf("error establishing yamux session: %w", err)
+		}
+
+		select {
+		case accept := <-m.router.defaultCh:
+			return accept.conn, accept.err
+		case <-m.closeCh:
+			return nil, yamux.ErrSessionShutdown
+		}
+	}
+
 	session, err := m.session()
 	if err != nil {
 		return nil, fmt.Errorf("error establishing yamux session: %w", err)
@@ -144,6 +238,19 @@ func (m *GRPCServerMuxer) Addr() net.Addr {
 }
 
 func (m *GRPCServerMuxer) Close() error {
+	if m.router != nil {
+		m.shutdown()
+		_ = m.ln.Close()
+
+		m.sessionMu.Lock()
+		sess := m.sess
+		m.sessionMu.Unlock()
+		if sess == nil {
+			return nil
+		}
+		return sess.Close()
+	}
+
 	session, err := m.session()
 	if err != nil {
 		return err
@@ -157,6 +264,10 @@ func (m *GRPCServerMuxer) Enabled() bool {
 }
 
 func (m *GRPCServerMuxer) Listener(id uint32, doneCh <-chan struct{}) (net.Listener, error) {
+	if m.router != nil {
+		return m.router.listener(id, m.addr, doneCh), nil
+	}
+
 	sess, err := m.session()
 	if err != nil {
 		return nil, err
@@ -170,7 +281,7 @@ func (m *GRPCServerMuxer) Listener(id uint32, doneCh <-chan struct{}) (net.Liste
 	return ln, nil
 }
 
-func (m *GRPCServerMuxer) Dial() (net.Conn, error) {
+func (m *GRPCServerMuxer) Dial(id uint32) (net.Conn, error) {
 	sess, err := m.session()
 	if err != nil {
 		return nil, err
@@ -180,11 +291,28 @@ func (m *GRPCServerMuxer) Dial() (net.Conn, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error dialling new server stream: %w", err)
 	}
+	if m.router != nil {
+		if err := writeStreamID(stream, id); err != nil {
+			_ = stream.Close()
+			return nil, err
+		}
+	}
 
 	return stream, nil
 }
 
+func (m *GRPCServerMuxer) StreamIDs() bool {
+	return m.router != nil
+}
+
 func (m *GRPCServerMuxer) AcceptKnock(id uint32) error {
+	if m.router != nil {
+		if !m.router.hasListener(id) {
+			return fmt.Errorf("no listener for id %d", id)
+		}
+		return nil
+	}
+
 	m.knockCh <- id
 	return nil
 }
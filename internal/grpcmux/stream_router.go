@@ -0,0 +1,122 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package grpcmux
+
+import (
+	"net"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/yamux"
+)
+
+// streamRouter routes multiplexed connections starting with their stream ID
+// to the listener for that ID. It is used by both sides of the connection
+// when they support stream IDs.
+type streamRouter struct {
+	logger hclog.Logger
+
+	// defaultCh receives the connections for stream ID 0, the gRPC broker's
+	// control services. It is nil on the client side, which doesn't serve
+	// them.
+	defaultCh chan acceptResult
+
+	mu        sync.Mutex
+	listeners map[uint32]*blockedServerListener
+}
+
+func newStreamRouter(logger hclog.Logger, serveDefault bool) *streamRouter {
+	r := &streamRouter{
+		logger:    logger,
+		listeners: make(map[uint32]*blockedServerListener),
+	}
+	if serveDefault {
+		r.defaultCh = make(chan acceptResult)
+	}
+	return r
+}
+
+// listener returns a listener for the connections routed to the given ID,
+// until doneCh is closed.
+func (r *streamRouter) listener(id uint32, addr net.Addr, doneCh <-chan struct{}) net.Listener {
+	ln := newBlockedServerListener(addr, doneCh)
+
+	r.mu.Lock()
+	r.listeners[id] = ln
+	r.mu.Unlock()
+
+	go func() {
+		<-doneCh
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		// The ID may have been listened on again since.
+		if r.listeners[id] == ln {
+			delete(r.listeners, id)
+		}
+	}()
+
+	return ln
+}
+
+// hasListener returns true if there is a listener for the given ID.
+func (r *streamRouter) hasListener(id uint32) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.listeners[id]
+	return ok
+}
+
+// run accepts connections from the session until it is closed, and routes
+// each of them according to its stream ID.
+func (r *streamRouter) run(sess *yamux.Session) {
+	for {
+		conn, err := sess.Accept()
+		if err != nil {
+			return
+		}
+
+		// Route connections concurrently, so that a connection slow to send
+		// its stream ID doesn't hold up the others.
+		go r.route(sess, conn)
+	}
+}
+
+func (r *streamRouter) route(sess *yamux.Session, conn net.Conn) {
+	id, err := readStreamID(conn)
+	if err != nil {
+		r.logger.Debug("dropping multiplexed connection", "error", err)
+		_ = conn.Close()
+		return
+	}
+
+	if id == 0 && r.defaultCh != nil {
+		r.logger.Debug("sending conn to default listener")
+		select {
+		case r.defaultCh <- acceptResult{conn: conn}:
+		case <-sess.CloseChan():
+			_ = conn.Close()
+		}
+		return
+	}
+
+	r.mu.Lock()
+	ln, ok := r.listeners[id]
+	r.mu.Unlock()
+	if !ok {
+		r.logger.Debug("dropping multiplexed connection for ID without a listener", "id", id)
+		_ = conn.Close()
+		return
+	}
+
+	r.logger.Debug("sending conn to brokered listener", "id", id)
+	select {
+	case ln.acceptCh <- acceptResult{conn: conn}:
+	case <-ln.doneCh:
+		_ = conn.Close()
+	case <-sess.CloseChan():
+		_ = conn.Close()
+	}
+}
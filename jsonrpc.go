@@ -0,0 +1,92 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	JSONRPCParseError     = -32700
+	JSONRPCInvalidRequest = -32600
+	JSONRPCMethodNotFound = -32601
+	JSONRPCInvalidParams  = -32602
+	JSONRPCInternalError  = -32603
+
+	// JSONRPCServerError is used for errors returned by a JSONRPCHandler
+	// that aren't a *JSONRPCError.
+	JSONRPCServerError = -32000
+)
+
+const (
+	jsonrpcVersion = "2.0"
+
+	jsonrpcMethodPing     = "rpc.ping"
+	jsonrpcMethodShutdown = "rpc.shutdown"
+	jsonrpcMethodStdout   = "rpc.stdout"
+	jsonrpcMethodStderr   = "rpc.stderr"
+)
+
+// JSONRPCHandler serves the methods of a single plugin over JSON-RPC. method
+// is the method name without the plugin prefix, and the returned result is
+// encoded as JSON. The context is canceled when the host disconnects.
+//
+// If the returned error is a *JSONRPCError it is sent to the host as-is,
+// otherwise it is sent with the JSONRPCServerError code.
+type JSONRPCHandler func(ctx context.Context, method string, params json.RawMessage) (interface{}, error)
+
+// JSONRPCError is a JSON-RPC 2.0 error object.
+type JSONRPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *JSONRPCError) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// jsonrpcMessage is a JSON-RPC 2.0 request, notification or response.
+//
+// ID is empty for notifications. A null ID is kept as the raw "null", since
+// a request with a null ID still gets a response.
+type jsonrpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+}
+
+// jsonrpcOutput is the params of the rpc.stdout and rpc.stderr notifications.
+type jsonrpcOutput struct {
+	Data string `json:"data"`
+}
+
+// jsonrpcWriter writes newline-delimited messages. It is safe for concurrent
+// use.
+type jsonrpcWriter struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (w *jsonrpcWriter) write(msg *jsonrpcMessage) error {
+	msg.JSONRPC = jsonrpcVersion
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	_, err = w.w.Write(data)
+	return err
+}
@@ -0,0 +1,260 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// jsonrpcShutdownTimeout is how long Close waits for the plugin to
+// acknowledge the shutdown request.
+const jsonrpcShutdownTimeout = 2 * time.Second
+
+// JSONRPCClient connects to a plugin speaking ProtocolJSONRPC to dispense
+// plugin types.
+type JSONRPCClient struct {
+	Plugins map[string]Plugin
+
+	conn    io.ReadWriteCloser
+	w       *jsonrpcWriter
+	doneCtx context.Context
+
+	stdout io.Writer
+	stderr io.Writer
+
+	lock    sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan *jsonrpcMessage
+	err     error
+	closed  chan struct{}
+
+	// idle tracks the calls to the dispensed plugins, if set.
+	idle *idleTracker
+}
+
+// newJSONRPCClientProtocol is the ClientProtocolFactory for ProtocolJSONRPC.
+func newJSONRPCClientProtocol(cfg *ClientProtocolConfig) (ClientProtocol, error) {
+	conn, err := cfg.Dial(cfg.DoneCtx)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TLSConfig != nil {
+		conn = tls.Client(conn, cfg.TLSConfig)
+	}
+
+	client := newJSONRPCClient(cfg.DoneCtx, conn, cfg.Plugins, cfg.SyncStdout, cfg.SyncStderr)
+	if cfg.client != nil {
+		client.idle = cfg.client.idle
+	}
+	return client, nil
+}
+
+// newJSONRPCClient creates a client from an already-open connection and
+// starts reading responses from it. Plugin output is written to stdout and
+// stderr, which may be nil.
+func newJSONRPCClient(doneCtx context.Context, conn io.ReadWriteCloser, plugins map[string]Plugin, stdout, stderr io.Writer) *JSONRPCClient {
+	c := &JSONRPCClient{
+		Plugins: plugins,
+		conn:    conn,
+		w:       &jsonrpcWriter{w: conn},
+		doneCtx: doneCtx,
+		stdout:  stdout,
+		stderr:  stderr,
+		pending: make(map[uint64]chan *jsonrpcMessage),
+		closed:  make(chan struct{}),
+	}
+	go c.run()
+
+	return c
+}
+
+// ClientProtocol impl.
+func (c *JSONRPCClient) Close() error {
+	// Ask the plugin to exit. It may already be gone or hung, so we don't
+	// care if this fails, and close the connection if it doesn't answer in
+	// time so that the request can't block either.
+	ctx, cancel := context.WithTimeout(c.doneCtx, jsonrpcShutdownTimeout)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() { _ = c.conn.Close() })
+	_ = c.Call(ctx, jsonrpcMethodShutdown, nil, nil)
+	stop()
+	return c.conn.Close()
+}
+
+// ClientProtocol impl.
+func (c *JSONRPCClient) Dispense(name string) (interface{}, error) {
+	return c.DispenseContext(context.Background(), name)
+}
+
+// DispenseContext is Dispense, but the context passed to the plugin's
+// JSONRPCClient also carries the values of ctx, such as trace spans. It is
+// still only cancelled once the plugin exits.
+func (c *JSONRPCClient) DispenseContext(ctx context.Context, name string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	raw, ok := c.Plugins[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown plugin type: %s", name)
+	}
+
+	p, ok := raw.(JSONRPCPlugin)
+	if !ok {
+		return nil, fmt.Errorf("plugin %q doesn't support JSON-RPC", name)
+	}
+
+	return p.JSONRPCClient(withValues(c.doneCtx, ctx), &JSONRPCCaller{client: c, plugin: name})
+}
+
+// ClientProtocol impl.
+func (c *JSONRPCClient) Ping() error {
+	return c.Call(c.doneCtx, jsonrpcMethodPing, nil, nil)
+}
+
+// Call calls the given method and waits for its response. params must
+// encode to a JSON object or array, or be nil. If result is non-nil, the
+// method's result is decoded into it. Errors returned by the plugin are
+// *JSONRPCError values.
+func (c *JSONRPCClient) Call(ctx context.Context, method string, params, result interface{}) error {
+	req := &jsonrpcMessage{Method: method}
+	if params != nil {
+		var err error
+		req.Params, err = json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("error encoding params: %w", err)
+		}
+	}
+
+	c.lock.Lock()
+	if c.err != nil {
+		c.lock.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *jsonrpcMessage, 1)
+	c.pending[id] = ch
+	c.lock.Unlock()
+
+	defer func() {
+		c.lock.Lock()
+		delete(c.pending, id)
+		c.lock.Unlock()
+	}()
+
+	req.ID = json.RawMessage(strconv.FormatUint(id, 10))
+	if err := c.w.write(req); err != nil {
+		return err
+	}
+
+	var resp *jsonrpcMessage
+	select {
+	case resp = <-ch:
+	case <-c.closed:
+		// The response may have arrived just before the connection closed.
+		select {
+		case resp = <-ch:
+		default:
+			return c.err
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result != nil {
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("error decoding result: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// run reads messages from the plugin until the connection is closed.
+func (c *JSONRPCClient) run() {
+	dec := json.NewDecoder(c.conn)
+	for {
+		var msg jsonrpcMessage
+		if err := dec.Decode(&msg); err != nil {
+			c.lock.Lock()
+			c.err = fmt.Errorf("plugin connection closed: %w", err)
+			if errors.Is(err, io.EOF) {
+				c.err = errors.New("plugin connection closed")
+			}
+			c.lock.Unlock()
+			close(c.closed)
+			return
+		}
+
+		if msg.Method != "" {
+			c.notify(&msg)
+			continue
+		}
+
+		if len(msg.ID) == 0 {
+			continue
+		}
+		id, err := strconv.ParseUint(string(msg.ID), 10, 64)
+		if err != nil {
+			// Not one of ours, for example an error about a request the
+			// plugin couldn't parse.
+			continue
+		}
+
+		c.lock.Lock()
+		ch := c.pending[id]
+		c.lock.Unlock()
+		if ch != nil {
+			ch <- &msg
+		}
+	}
+}
+
+// notify handles a notification sent by the plugin.
+func (c *JSONRPCClient) notify(msg *jsonrpcMessage) {
+	var w io.Writer
+	switch msg.Method {
+	case jsonrpcMethodStdout:
+		w = c.stdout
+	case jsonrpcMethodStderr:
+		w = c.stderr
+	}
+	if w == nil {
+		return
+	}
+
+	var out jsonrpcOutput
+	if err := json.Unmarshal(msg.Params, &out); err != nil {
+		return
+	}
+	_, _ = io.WriteString(w, out.Data)
+}
+
+// JSONRPCCaller calls the methods of a single dispensed plugin over
+// JSON-RPC.
+type JSONRPCCaller struct {
+	client *JSONRPCClient
+	plugin string
+}
+
+// Call calls the given method of the plugin. See JSONRPCClient.Call.
+func (c *JSONRPCCaller) Call(ctx context.Context, method string, params, result interface{}) error {
+	if c.client.idle != nil {
+		c.client.idle.begin()
+		defer c.client.idle.end()
+	}
+	return c.client.Call(ctx, c.plugin+"."+method, params, result)
+}
@@ -0,0 +1,296 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// JSONRPCServer is a ServerProtocol implementation that serves plugins
+// implementing JSONRPCPlugin over ProtocolJSONRPC.
+type JSONRPCServer struct {
+	Plugins map[string]Plugin
+
+	// Stdout, Stderr are forwarded to connected hosts as notifications.
+	Stdout io.Reader
+	Stderr io.Reader
+
+	// DoneCh should be set to a non-nil channel that will be closed
+	// when the host requests the server to end.
+	DoneCh chan<- struct{}
+
+	// persistent is set in debug mode, where the server keeps running when
+	// a host asks it to shut down.
+	persistent bool
+
+	handlers map[string]JSONRPCHandler
+
+	lock  sync.Mutex
+	conns map[net.Conn]*jsonrpcWriter
+}
+
+// newJSONRPCServerProtocol is the ServerProtocolFactory for ProtocolJSONRPC.
+func newJSONRPCServerProtocol(cfg *ServerProtocolConfig) (ServerProtocol, error) {
+	if cfg.TLS != nil {
+		cfg.Listener = tls.NewListener(cfg.Listener, cfg.TLS)
+	}
+
+	return &JSONRPCServer{
+		Plugins: cfg.Plugins,
+		Stdout:  cfg.Stdout,
+		Stderr:  cfg.Stderr,
+		DoneCh:  cfg.DoneCh,
+
+		persistent: cfg.ServeConfig != nil && cfg.ServeConfig.Debug != nil,
+	}, nil
+}
+
+// ServerProtocol impl.
+func (s *JSONRPCServer) Init() error {
+	s.handlers = make(map[string]JSONRPCHandler)
+	for name, raw := range s.Plugins {
+		p, ok := raw.(JSONRPCPlugin)
+		if !ok {
+			return fmt.Errorf("%q is not a JSON-RPC plugin", name)
+		}
+
+		handler, err := p.JSONRPCServer()
+		if err != nil {
+			return fmt.Errorf("error initializing %q plugin: %w", name, err)
+		}
+		s.handlers[name] = handler
+	}
+
+	s.conns = make(map[net.Conn]*jsonrpcWriter)
+	return nil
+}
+
+// ServerProtocol impl.
+func (s *JSONRPCServer) Config() string { return "" }
+
+// ServerProtocol impl.
+func (s *JSONRPCServer) Serve(lis net.Listener) {
+	defer s.done()
+
+	go s.forwardOutput(jsonrpcMethodStdout, s.Stdout)
+	go s.forwardOutput(jsonrpcMethodStderr, s.Stderr)
+
+	for {
+		conn, err := lis.Accept()
+		if err != nil {
+			severity := "ERR"
+			if errors.Is(err, net.ErrClosed) {
+				severity = "DEBUG"
+			}
+			log.Printf("[%s] plugin: plugin server: %s", severity, err)
+			return
+		}
+
+		go s.ServeConn(conn)
+	}
+}
+
+// Stop closes all connections. It is called when serving is cancelled.
+func (s *JSONRPCServer) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+}
+
+// ServeConn runs a single connection.
+//
+// ServeConn blocks, serving the connection until the host hangs up. Requests
+// are handled concurrently.
+func (s *JSONRPCServer) ServeConn(conn net.Conn) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w := &jsonrpcWriter{w: conn}
+	s.lock.Lock()
+	s.conns[conn] = w
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		delete(s.conns, conn)
+		s.lock.Unlock()
+		_ = conn.Close()
+	}()
+
+	dec := json.NewDecoder(conn)
+	for {
+		msg := new(jsonrpcMessage)
+		err := dec.Decode(msg)
+
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case err == nil:
+			go s.handle(ctx, w, msg)
+
+		case errors.As(err, &typeErr):
+			// The message was valid JSON, so we can carry on.
+			_ = w.write(&jsonrpcMessage{
+				ID:    jsonrpcNullID(),
+				Error: &JSONRPCError{Code: JSONRPCInvalidRequest, Message: err.Error()},
+			})
+
+		case errors.As(err, &syntaxErr):
+			// We can't find the start of the next message, so all we can do
+			// is report the error and hang up.
+			_ = w.write(&jsonrpcMessage{
+				ID:    jsonrpcNullID(),
+				Error: &JSONRPCError{Code: JSONRPCParseError, Message: err.Error()},
+			})
+			return
+
+		default:
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				log.Printf("[ERR] plugin: error reading JSON-RPC request: %s", err)
+			}
+			return
+		}
+	}
+}
+
+// handle runs a single request and writes its response.
+func (s *JSONRPCServer) handle(ctx context.Context, w *jsonrpcWriter, msg *jsonrpcMessage) {
+	result, err := s.call(ctx, msg)
+
+	// Notifications get no response.
+	if len(msg.ID) == 0 {
+		return
+	}
+
+	resp := &jsonrpcMessage{ID: msg.ID}
+	if err == nil {
+		resp.Result, err = json.Marshal(result)
+		if err != nil {
+			err = &JSONRPCError{Code: JSONRPCInternalError, Message: err.Error()}
+		}
+	}
+	if err != nil {
+		var rpcErr *JSONRPCError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &JSONRPCError{Code: JSONRPCServerError, Message: err.Error()}
+		}
+		resp.Result = nil
+		resp.Error = rpcErr
+	}
+
+	if err := w.write(resp); err != nil {
+		log.Printf("[ERR] plugin: error writing JSON-RPC response: %s", err)
+		return
+	}
+
+	// We only stop once the host has been told that we will.
+	if msg.Method == jsonrpcMethodShutdown && resp.Error == nil && !s.persistent {
+		s.done()
+	}
+}
+
+// call dispatches a request to the control methods or a plugin's handler.
+func (s *JSONRPCServer) call(ctx context.Context, msg *jsonrpcMessage) (interface{}, error) {
+	if msg.JSONRPC != jsonrpcVersion || msg.Method == "" {
+		return nil, &JSONRPCError{Code: JSONRPCInvalidRequest, Message: "invalid request"}
+	}
+
+	switch msg.Method {
+	case jsonrpcMethodPing, jsonrpcMethodShutdown:
+		return nil, nil
+	}
+
+	name, method, ok := strings.Cut(msg.Method, ".")
+	handler, found := s.handlers[name]
+	if !ok || !found {
+		return nil, &JSONRPCError{
+			Code:    JSONRPCMethodNotFound,
+			Message: fmt.Sprintf("method not found: %s", msg.Method),
+		}
+	}
+
+	return handler(ctx, method, msg.Params)
+}
+
+// forwardOutput sends everything read from r to all connected hosts.
+func (s *JSONRPCServer) forwardOutput(method string, r io.Reader) {
+	if r == nil {
+		return
+	}
+
+	// A rune split across reads is held back until the rest of it is read,
+	// as each part on its own isn't valid UTF-8.
+	buf := make([]byte, 4096)
+	partial := 0
+	for {
+		n, err := r.Read(buf[partial:])
+		data := buf[:partial+n]
+		partial = 0
+		if err == nil {
+			partial = partialRuneLen(data)
+			data = data[:len(data)-partial]
+		}
+		if len(data) > 0 {
+			params, _ := json.Marshal(&jsonrpcOutput{Data: string(data)})
+
+			s.lock.Lock()
+			writers := make([]*jsonrpcWriter, 0, len(s.conns))
+			for _, w := range s.conns {
+				writers = append(writers, w)
+			}
+			s.lock.Unlock()
+
+			for _, w := range writers {
+				_ = w.write(&jsonrpcMessage{Method: method, Params: params})
+			}
+		}
+		if err != nil {
+			return
+		}
+		copy(buf, buf[len(data):len(data)+partial])
+	}
+}
+
+// partialRuneLen returns the length of the incomplete UTF-8 encoded rune at
+// the end of p, or 0 if p ends with a complete rune.
+func partialRuneLen(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i > len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if utf8.FullRune(p[i:]) {
+				return 0
+			}
+			return len(p) - i
+		}
+	}
+	return 0
+}
+
+func (s *JSONRPCServer) done() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.DoneCh != nil {
+		close(s.DoneCh)
+		s.DoneCh = nil
+	}
+}
+
+// jsonrpcNullID is the ID of responses to requests whose ID couldn't be
+// read.
+func jsonrpcNullID() json.RawMessage {
+	return json.RawMessage("null")
+}
@@ -0,0 +1,317 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+// testJSONRPCInterfacePlugin serves the parts of testInterface that are
+// convenient to express as JSON.
+type testJSONRPCInterfacePlugin struct {
+	NetRPCUnsupportedPlugin
+}
+
+func (p *testJSONRPCInterfacePlugin) JSONRPCServer() (JSONRPCHandler, error) {
+	impl := &testInterfaceImpl{logger: hclog.NewNullLogger()}
+	return func(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+		switch method {
+		case "Double":
+			var args []int
+			if err := json.Unmarshal(params, &args); err != nil || len(args) != 1 {
+				return nil, &JSONRPCError{Code: JSONRPCInvalidParams, Message: "expected [int]"}
+			}
+			return impl.Double(args[0]), nil
+
+		case "PrintStdio":
+			var args struct{ Stdout, Stderr string }
+			if err := json.Unmarshal(params, &args); err != nil {
+				return nil, &JSONRPCError{Code: JSONRPCInvalidParams, Message: err.Error()}
+			}
+			impl.PrintStdio([]byte(args.Stdout), []byte(args.Stderr))
+			return nil, nil
+
+		case "Fail":
+			return nil, errors.New("failed")
+		}
+
+		return nil, &JSONRPCError{Code: JSONRPCMethodNotFound, Message: "method not found: " + method}
+	}, nil
+}
+
+func (p *testJSONRPCInterfacePlugin) JSONRPCClient(ctx context.Context, c *JSONRPCCaller) (interface{}, error) {
+	return &testJSONRPCInterfaceClient{ctx: ctx, caller: c}, nil
+}
+
+type testJSONRPCInterfaceClient struct {
+	ctx    context.Context
+	caller *JSONRPCCaller
+}
+
+func (c *testJSONRPCInterfaceClient) Double(v int) (int, error) {
+	var result int
+	err := c.caller.Call(c.ctx, "Double", []int{v}, &result)
+	return result, err
+}
+
+func (c *testJSONRPCInterfaceClient) PrintStdio(stdout, stderr string) error {
+	return c.caller.Call(c.ctx, "PrintStdio", map[string]string{"Stdout": stdout, "Stderr": stderr}, nil)
+}
+
+var testJSONRPCPluginMap = map[string]Plugin{
+	"test": new(testJSONRPCInterfacePlugin),
+}
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use.
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+func TestClient_jsonrpc(t *testing.T) {
+	stdout := new(syncBuffer)
+	process := helperProcess("test-jsonrpc")
+	c := NewClient(&ClientConfig{
+		Cmd:              process,
+		HandshakeConfig:  testHandshake,
+		Plugins:          testJSONRPCPluginMap,
+		AllowedProtocols: []Protocol{ProtocolJSONRPC},
+		SyncStdout:       stdout,
+	})
+	defer c.Kill()
+
+	client, err := c.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v := c.Protocol(); v != ProtocolJSONRPC {
+		t.Fatalf("bad: %s", v)
+	}
+	if err := client.Ping(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	raw, err := client.Dispense("test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	impl := raw.(*testJSONRPCInterfaceClient)
+
+	n, err := impl.Double(21)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n != 42 {
+		t.Fatalf("bad: %d", n)
+	}
+
+	// Plugin output is forwarded to SyncStdout
+	if err := impl.PrintStdio("hello", ""); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for stdout.String() != "hello" {
+		if time.Now().After(deadline) {
+			t.Fatalf("bad stdout: %q", stdout.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Errors from the plugin come back as *JSONRPCError
+	err = impl.caller.Call(context.Background(), "Fail", nil, nil)
+	var rpcErr *JSONRPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != JSONRPCServerError || rpcErr.Message != "failed" {
+		t.Fatalf("bad error: %#v", err)
+	}
+
+	// Kill it
+	c.Kill()
+	if !c.Exited() {
+		t.Fatal("should say client has exited")
+	}
+	if c.killed() {
+		t.Fatal("process failed to exit gracefully")
+	}
+}
+
+func TestJSONRPCServer_wire(t *testing.T) {
+	doneCh := make(chan struct{})
+	server := &JSONRPCServer{
+		Plugins: testJSONRPCPluginMap,
+		DoneCh:  doneCh,
+	}
+	if err := server.Init(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	client, conn := net.Pipe()
+	defer client.Close()
+	go server.ServeConn(conn)
+
+	r := bufio.NewReader(client)
+	roundTrip := func(req string) string {
+		t.Helper()
+		if err := client.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if _, err := client.Write([]byte(req + "\n")); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		resp, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return strings.TrimSpace(resp)
+	}
+
+	cases := []struct {
+		req, resp string
+	}{
+		{
+			`{"jsonrpc":"2.0","id":1,"method":"rpc.ping"}`,
+			`{"jsonrpc":"2.0","id":1,"result":null}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":"a","method":"test.Double","params":[4]}`,
+			`{"jsonrpc":"2.0","id":"a","result":8}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":2,"method":"test.Double","params":{}}`,
+			`{"jsonrpc":"2.0","id":2,"error":{"code":-32602,"message":"expected [int]"}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":3,"method":"other.Double"}`,
+			`{"jsonrpc":"2.0","id":3,"error":{"code":-32601,"message":"method not found: other.Double"}}`,
+		},
+		{
+			// A null ID is still a request, not a notification.
+			`{"jsonrpc":"2.0","id":null,"method":"rpc.ping"}`,
+			`{"jsonrpc":"2.0","id":null,"result":null}`,
+		},
+		{
+			`{"id":4,"method":"rpc.ping"}`,
+			`{"jsonrpc":"2.0","id":4,"error":{"code":-32600,"message":"invalid request"}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","id":5,"method":"rpc.shutdown"}`,
+			`{"jsonrpc":"2.0","id":5,"result":null}`,
+		},
+	}
+	for _, tc := range cases {
+		if resp := roundTrip(tc.req); resp != tc.resp {
+			t.Fatalf("request %s\nexpected: %s\ngot:      %s", tc.req, tc.resp, resp)
+		}
+	}
+
+	select {
+	case <-doneCh:
+	case <-time.After(2 * time.Second):
+		t.Fatal("rpc.shutdown should stop the server")
+	}
+
+	// Malformed JSON gets a parse error and the connection is closed.
+	resp := roundTrip(`{not json`)
+	if !strings.HasPrefix(resp, `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,`) {
+		t.Fatalf("bad: %s", resp)
+	}
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Fatal("connection should be closed")
+	}
+}
+
+func TestJSONRPCServer_outputSplitRune(t *testing.T) {
+	server := &JSONRPCServer{Plugins: testJSONRPCPluginMap}
+	if err := server.Init(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	client, conn := net.Pipe()
+	defer client.Close()
+	go server.ServeConn(conn)
+
+	// Wait for the connection to be registered.
+	r := bufio.NewReader(client)
+	if _, err := client.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"rpc.ping"}` + "\n")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := r.ReadString('\n'); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Each write is a separate read, splitting the two bytes of "é".
+	stdout_r, stdout_w := io.Pipe()
+	go server.forwardOutput(jsonrpcMethodStdout, stdout_r)
+	go func() {
+		_, _ = stdout_w.Write([]byte("h\xc3"))
+		_, _ = stdout_w.Write([]byte("\xa9llo"))
+		_ = stdout_w.Close()
+	}()
+
+	if err := client.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	var out string
+	for out != "h\u00e9llo" {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("output so far %q: %s", out, err)
+		}
+		var msg jsonrpcMessage
+		var params jsonrpcOutput
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !utf8.ValidString(params.Data) {
+			t.Fatalf("invalid UTF-8 in output: %q", params.Data)
+		}
+		out += params.Data
+	}
+}
+
+func TestJSONRPCClient_closeUnresponsive(t *testing.T) {
+	// The plugin never reads the shutdown request, let alone answers it.
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	client := newJSONRPCClient(context.Background(), conn, testJSONRPCPluginMap, nil, nil)
+
+	done := make(chan struct{})
+	go func() {
+		_ = client.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * jsonrpcShutdownTimeout):
+		t.Fatal("Close should not wait for an unresponsive plugin")
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrManagerShutdown is returned when adding a client to a Manager that was
+// shut down.
+var ErrManagerShutdown = errors.New("plugin manager is shut down")
+
+// defaultManager manages the clients created with ClientConfig.Managed.
+// CleanupClients kills its clients, but more can be added afterwards.
+var defaultManager = NewManager()
+
+// Manager manages the lifecycle of a set of named plugin clients. Unlike
+// ClientConfig.Managed and CleanupClients, which share a single global set
+// of clients, each Manager is independent, so libraries and tests can clean
+// up their own plugins without affecting the others.
+//
+// The zero value is not usable; create managers with NewManager.
+type Manager struct {
+	lock     sync.Mutex
+	clients  []managedClient
+	byName   map[string]*Client
+	shutdown bool
+}
+
+// managedClient is a client of a Manager. Clients added by
+// ClientConfig.Managed have no name.
+type managedClient struct {
+	name   string
+	client *Client
+}
+
+// ClientStatus is the status of a client of a Manager.
+type ClientStatus struct {
+	// Name is the name the client was added with.
+	Name string
+
+	// ID is the ID of the plugin process, or "" if it isn't running.
+	ID string
+
+	// Started is true once the plugin was started, and Exited once it
+	// exited. ExitErr is the error it exited with, if any.
+	Started bool
+	Exited  bool
+	ExitErr error
+
+	// Protocol and Version are the protocol and the version negotiated
+	// with the plugin, once it is started.
+	Protocol Protocol
+	Version  int
+}
+
+// NewManager returns an empty Manager.
+func NewManager() *Manager {
+	return &Manager{byName: make(map[string]*Client)}
+}
+
+// NewClient creates a client with NewClient and adds it to the manager
+// under the given name, which must be unique within the manager. The client
+// is killed when the manager is shut down, or can be killed earlier.
+func (m *Manager) NewClient(name string, config *ClientConfig) (*Client, error) {
+	if name == "" {
+		return nil, errors.New("plugin manager clients must have a name")
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.shutdown {
+		return nil, ErrManagerShutdown
+	}
+	if _, ok := m.byName[name]; ok {
+		return nil, fmt.Errorf("plugin manager already has a client named %q", name)
+	}
+
+	c := NewClient(config)
+	m.byName[name] = c
+	m.clients = append(m.clients, managedClient{name: name, client: c})
+	return c, nil
+}
+
+// add adds an unnamed client to the manager.
+func (m *Manager) add(c *Client) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.clients = append(m.clients, managedClient{client: c})
+}
+
+// Client returns the client with the given name, if any.
+func (m *Manager) Client(name string) (*Client, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	c, ok := m.byName[name]
+	return c, ok
+}
+
+// Names returns the names of the clients of the manager, in the order they
+// were added.
+func (m *Manager) Names() []string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	names := make([]string, 0, len(m.byName))
+	for _, mc := range m.clients {
+		if mc.name != "" {
+			names = append(names, mc.name)
+		}
+	}
+	return names
+}
+
+// Remove removes the client with the given name from the manager without
+// killing it, and returns it. The caller is then responsible for killing
+// it.
+func (m *Manager) Remove(name string) (*Client, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	c, ok := m.byName[name]
+	if !ok {
+		return nil, false
+	}
+	delete(m.byName, name)
+	for i, mc := range m.clients {
+		if mc.name == name {
+			m.clients = append(m.clients[:i], m.clients[i+1:]...)
+			break
+		}
+	}
+	return c, true
+}
+
+// Status returns the status of the clients of the manager, in the order
+// they were added.
+func (m *Manager) Status() []ClientStatus {
+	m.lock.Lock()
+	clients := append([]managedClient(nil), m.clients...)
+	m.lock.Unlock()
+
+	statuses := make([]ClientStatus, 0, len(clients))
+	for _, mc := range clients {
+		s := mc.client.status()
+		s.Name = mc.name
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+// Shutdown kills all the clients of the manager in parallel, and prevents
+// new clients from being added. The plugins are given until ctx is done to
+// exit gracefully, after which they are killed forcefully, see
+// Client.KillContext.
+//
+// Shutdown returns once all the plugins exited, or ctx.Err() if they
+// haven't by the time ctx is done, in which case they are still being
+// killed in the background.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	return m.killAll(ctx, true)
+}
+
+// killAll removes all the clients of the manager and kills them, as
+// described by Shutdown. If shutdown is false, new clients can still be
+// added.
+func (m *Manager) killAll(ctx context.Context, shutdown bool) error {
+	m.lock.Lock()
+	clients := m.clients
+	m.clients = nil
+	m.byName = make(map[string]*Client)
+	m.shutdown = m.shutdown || shutdown
+	m.lock.Unlock()
+
+	var wg sync.WaitGroup
+	for _, mc := range clients {
+		wg.Add(1)
+		go func(c *Client) {
+			defer wg.Done()
+			c.KillContext(ctx)
+		}(mc.client)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		select {
+		case <-done:
+			return nil
+		default:
+			return ctx.Err()
+		}
+	}
+}
+
+// status returns the status of the client, without its name.
+func (c *Client) status() ClientStatus {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	s := ClientStatus{
+		Started:  c.address != nil,
+		Exited:   c.exited,
+		ExitErr:  c.exitErr,
+		Protocol: c.protocol,
+		Version:  c.negotiatedVersion,
+	}
+	if c.runner != nil {
+		s.ID = c.runner.ID()
+	}
+	return s
+}
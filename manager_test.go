@@ -0,0 +1,118 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	m := NewManager()
+	defer func() { _ = m.Shutdown(context.Background()) }()
+
+	for _, name := range []string{"b", "a"} {
+		if _, err := m.NewClient(name, &ClientConfig{
+			Cmd:             helperProcess("test-interface"),
+			HandshakeConfig: testHandshake,
+			Plugins:         testPluginMap,
+		}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	if _, err := m.NewClient("a", &ClientConfig{}); err == nil {
+		t.Fatal("expected error for duplicate name")
+	}
+	if names := m.Names(); !reflect.DeepEqual(names, []string{"b", "a"}) {
+		t.Fatalf("bad: %#v", names)
+	}
+
+	a, ok := m.Client("a")
+	if !ok {
+		t.Fatal("client a not found")
+	}
+	if _, err := a.Start(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	status := m.Status()
+	if len(status) != 2 {
+		t.Fatalf("bad: %#v", status)
+	}
+	if s := status[0]; s.Name != "b" || s.Started || s.ID != "" {
+		t.Fatalf("bad: %#v", s)
+	}
+	if s := status[1]; s.Name != "a" || !s.Started || s.Exited || s.ID == "" || s.Protocol != ProtocolNetRPC {
+		t.Fatalf("bad: %#v", s)
+	}
+
+	// Removed clients aren't killed by the manager.
+	b, ok := m.Remove("b")
+	if !ok {
+		t.Fatal("client b not found")
+	}
+	defer b.Kill()
+	if _, ok := m.Client("b"); ok {
+		t.Fatal("client b should be removed")
+	}
+
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !a.Exited() {
+		t.Fatal("client a should have exited")
+	}
+	if len(m.Status()) != 0 {
+		t.Fatalf("bad: %#v", m.Status())
+	}
+
+	_, err := m.NewClient("c", &ClientConfig{})
+	if !errors.Is(err, ErrManagerShutdown) {
+		t.Fatalf("bad: %v", err)
+	}
+}
+
+func TestManager_Shutdown_independent(t *testing.T) {
+	config := func() *ClientConfig {
+		return &ClientConfig{
+			Cmd:             helperProcess("test-interface"),
+			HandshakeConfig: testHandshake,
+			Plugins:         testPluginMap,
+		}
+	}
+
+	m1, m2 := NewManager(), NewManager()
+	defer func() { _ = m2.Shutdown(context.Background()) }()
+
+	c1, err := m1.NewClient("test", config())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	c2, err := m2.NewClient("test", config())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	for _, c := range []*Client{c1, c2} {
+		if _, err := c.Client(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m1.Shutdown(ctx); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !c1.Exited() {
+		t.Fatal("c1 should have exited")
+	}
+	if c2.Exited() {
+		t.Fatal("c2 should still be running")
+	}
+}
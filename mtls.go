@@ -8,6 +8,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
@@ -74,3 +75,28 @@ func generateCert() (cert []byte, privateKey []byte, err error) {
 
 	return cert, privateKey, nil
 }
+
+// generateServerTLSConfig generates a temporary server certificate and returns
+// a TLS configuration for the plugin server that only accepts clients
+// presenting a certificate from clientCertPool. The generated certificate is
+// returned so its public part can be handed back to the client.
+func generateServerTLSConfig(clientCertPool *x509.CertPool) (*tls.Config, tls.Certificate, error) {
+	certPEM, keyPEM, err := generateCert()
+	if err != nil {
+		return nil, tls.Certificate{}, err
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, tls.Certificate{}, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCertPool,
+		MinVersion:   tls.VersionTLS12,
+		RootCAs:      clientCertPool,
+		ServerName:   "localhost",
+	}, cert, nil
+}
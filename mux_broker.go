@@ -34,18 +34,24 @@ type MuxBroker struct {
 	session *yamux.Session
 	streams map[uint32]*muxBrokerPending
 
+	registry *brokerRegistry
+
 	sync.Mutex
 }
 
 type muxBrokerPending struct {
 	ch     chan net.Conn
 	doneCh chan struct{}
+
+	// closeCh is closed when the ID is closed with CloseID.
+	closeCh chan struct{}
 }
 
-func newMuxBroker(s *yamux.Session) *MuxBroker {
+func newMuxBroker(s *yamux.Session, config *BrokerConfig) *MuxBroker {
 	return &MuxBroker{
-		session: s,
-		streams: make(map[uint32]*muxBrokerPending),
+		session:  s,
+		streams:  make(map[uint32]*muxBrokerPending),
+		registry: newBrokerRegistry(config),
 	}
 }
 
@@ -58,11 +64,16 @@ func (m *MuxBroker) Accept(id uint32) (net.Conn, error) {
 	select {
 	case c = <-p.ch:
 		close(p.doneCh)
-	case <-time.After(5 * time.Second):
+	case <-p.closeCh:
+		return nil, errBrokerIDClosed(id)
+	case <-time.After(m.registry.timeout()):
 		m.Lock()
-		defer m.Unlock()
-		delete(m.streams, id)
+		if m.streams[id] == p {
+			delete(m.streams, id)
+		}
+		m.Unlock()
 
+		m.registry.expire(id)
 		return nil, fmt.Errorf("timeout waiting for accept")
 	}
 
@@ -72,7 +83,7 @@ func (m *MuxBroker) Accept(id uint32) (net.Conn, error) {
 		return nil, err
 	}
 
-	return c, nil
+	return m.registry.trackConn(id, BrokerAccept, c), nil
 }
 
 // AcceptAndServe is used to accept a specific stream ID and immediately
@@ -120,7 +131,47 @@ func (m *MuxBroker) Dial(id uint32) (net.Conn, error) {
 		return nil, fmt.Errorf("bad ack: %d (expected %d)", ack, id)
 	}
 
-	return stream, nil
+	return m.registry.trackConn(id, BrokerDial, stream), nil
+}
+
+// Connections returns the connections currently open on the broker that
+// were accepted or dialled by this side, sorted by ID.
+func (m *MuxBroker) Connections() []BrokerConn {
+	return m.registry.list()
+}
+
+// CloseID closes the connections open on the broker with the given ID, and
+// makes a pending Accept of the ID return an error.
+func (m *MuxBroker) CloseID(id uint32) error {
+	m.Lock()
+	p, ok := m.streams[id]
+	if ok {
+		delete(m.streams, id)
+		close(p.closeCh)
+		select {
+		case s := <-p.ch:
+			_ = s.Close()
+		default:
+		}
+	}
+	m.Unlock()
+
+	return m.registry.closeID(id)
+}
+
+// Stats returns counters of the broker's connections and IDs.
+func (m *MuxBroker) Stats() BrokerStats {
+	return m.registry.stats()
+}
+
+// AcceptStream implements StreamBroker. It is the same as Accept.
+func (m *MuxBroker) AcceptStream(id uint32) (net.Conn, error) {
+	return m.Accept(id)
+}
+
+// DialStream implements StreamBroker. It is the same as Dial.
+func (m *MuxBroker) DialStream(id uint32) (net.Conn, error) {
+	return m.Dial(id)
 }
 
 // NextId returns a unique ID to use next.
@@ -157,6 +208,10 @@ func (m *MuxBroker) Run() {
 		select {
 		case p.ch <- stream:
 		default:
+			// There is already a stream waiting for this ID.
+			_ = stream.Close()
+			m.registry.leak(id)
+			continue
 		}
 
 		// Wait for a timeout
@@ -174,8 +229,9 @@ func (m *MuxBroker) getStream(id uint32) *muxBrokerPending {
 	}
 
 	m.streams[id] = &muxBrokerPending{
-		ch:     make(chan net.Conn, 1),
-		doneCh: make(chan struct{}),
+		ch:      make(chan net.Conn, 1),
+		doneCh:  make(chan struct{}),
+		closeCh: make(chan struct{}),
 	}
 	return m.streams[id]
 }
@@ -186,7 +242,9 @@ func (m *MuxBroker) timeoutWait(id uint32, p *muxBrokerPending) {
 	timeout := false
 	select {
 	case <-p.doneCh:
-	case <-time.After(5 * time.Second):
+	case <-p.closeCh:
+		return
+	case <-time.After(m.registry.timeout()):
 		timeout = true
 	}
 
@@ -194,12 +252,18 @@ func (m *MuxBroker) timeoutWait(id uint32, p *muxBrokerPending) {
 	defer m.Unlock()
 
 	// Delete the stream so no one else can grab it
-	delete(m.streams, id)
+	if m.streams[id] == p {
+		delete(m.streams, id)
+	}
 
 	// If we timed out, then check if we have a channel in the buffer,
 	// and if so, close it.
 	if timeout {
-		s := <-p.ch
-		_ = s.Close()
+		select {
+		case s := <-p.ch:
+			_ = s.Close()
+			m.registry.leak(id)
+		default:
+		}
 	}
 }
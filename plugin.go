@@ -45,6 +45,30 @@ type GRPCPlugin interface {
 	GRPCClient(context.Context, *GRPCBroker, *grpc.ClientConn) (interface{}, error)
 }
 
+// JSONRPCPlugin is the interface that is implemented to serve/connect to
+// a plugin over JSON-RPC (ProtocolJSONRPC).
+//
+// The protocol is JSON-RPC 2.0 with one JSON message per line. Plugin
+// methods are called as "<plugin>.<method>", where <plugin> is the name the
+// plugin is dispensed with. The "rpc." prefix is reserved for control
+// methods: "rpc.ping" returns null, and "rpc.shutdown" returns null and then
+// asks the plugin to exit. Go plugins also forward their stdout and stderr
+// to the host as "rpc.stdout" and "rpc.stderr" notifications with params
+// {"data": "..."}.
+type JSONRPCPlugin interface {
+	// JSONRPCServer should return the handler for this plugin's methods.
+	// This is only needed for plugins written in Go; plugins in other
+	// languages implement the methods directly. Like GRPCServer, this is
+	// only called once.
+	JSONRPCServer() (JSONRPCHandler, error)
+
+	// JSONRPCClient should return the interface implementation for the
+	// plugin you're serving via JSON-RPC. The caller sends requests for this
+	// plugin's methods. The provided context will be canceled by go-plugin
+	// in the event of the plugin process exiting.
+	JSONRPCClient(context.Context, *JSONRPCCaller) (interface{}, error)
+}
+
 // NetRPCUnsupportedPlugin implements Plugin but returns errors for the
 // Server and Client functions. This will effectively disable support for
 // net/rpc based plugins.
@@ -192,7 +192,7 @@ func (impl *testInterfaceClient) PrintStdio(stdout, stderr []byte) {
 }
 
 func (impl *testInterfaceClient) Panic(msg string) error {
-	return nil
+	return impl.Client.Call("Plugin.Panic", msg, &struct{}{})
 }
 
 // testInterfaceServer is the RPC server for testInterfaceClient
@@ -211,6 +211,10 @@ func (s *testInterfaceServer) PrintKV(args map[string]interface{}, _ *struct{})
 	return nil
 }
 
+func (s *testInterfaceServer) Panic(msg string, _ *struct{}) error {
+	return s.Impl.Panic(msg)
+}
+
 // testPluginMap can be used for tests as a plugin map
 var testPluginMap = map[string]Plugin{
 	"test": new(testInterfacePlugin),
@@ -221,6 +225,16 @@ var testGRPCPluginMap = map[string]Plugin{
 	"test": new(testGRPCInterfacePlugin),
 }
 
+// testProtocols are the helper processes serving testInterface over each
+// protocol, with the plugin map to use for them, for tests covering both.
+var testProtocols = map[string]struct {
+	helper  string
+	plugins map[string]Plugin
+}{
+	"netrpc": {"test-interface", testPluginMap},
+	"grpc":   {"test-grpc", testGRPCPluginMap},
+}
+
 // testGRPCServer is the implementation of our GRPC service.
 type testGRPCServer struct {
 	grpctest.UnimplementedTestServer
@@ -568,6 +582,43 @@ func TestHelperProcess(*testing.T) {
 			GRPCServer:      DefaultGRPCServer,
 		})
 
+		// Shouldn't reach here but make sure we exit anyways
+		os.Exit(0)
+	case "test-jsonrpc":
+		Serve(&ServeConfig{
+			HandshakeConfig: testHandshake,
+			Plugins:         testJSONRPCPluginMap,
+			Protocol:        ProtocolJSONRPC,
+		})
+
+		// Shouldn't reach here but make sure we exit anyways
+		os.Exit(0)
+	case "test-protocols":
+		Serve(&ServeConfig{
+			HandshakeConfig: testHandshake,
+			Plugins:         testGRPCPluginMap,
+			GRPCServer:      DefaultGRPCServer,
+			Protocols:       []Protocol{ProtocolGRPC, ProtocolNetRPC},
+		})
+
+		// Shouldn't reach here but make sure we exit anyways
+		os.Exit(0)
+	case "test-netrpc-bridge":
+		Serve(&ServeConfig{
+			HandshakeConfig: testHandshake,
+			Plugins:         testNetRPCBridgePluginMap,
+			GRPCServer:      DefaultGRPCServer,
+		})
+
+		// Shouldn't reach here but make sure we exit anyways
+		os.Exit(0)
+	case "test-custom-protocol":
+		Serve(&ServeConfig{
+			HandshakeConfig: testHandshake,
+			Plugins:         testPluginMap,
+			Protocol:        testCustomProtocol,
+		})
+
 		// Shouldn't reach here but make sure we exit anyways
 		os.Exit(0)
 	case "test-grpc-tls":
@@ -4,8 +4,15 @@
 package plugin
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
 	"io"
 	"net"
+	"strings"
+	"sync"
+
+	hclog "github.com/hashicorp/go-hclog"
 )
 
 // Protocol is an enum representing the types of protocols.
@@ -15,6 +22,11 @@ const (
 	ProtocolInvalid Protocol = ""
 	ProtocolNetRPC  Protocol = "netrpc"
 	ProtocolGRPC    Protocol = "grpc"
+
+	// ProtocolJSONRPC is newline-delimited JSON-RPC 2.0, which is simple to
+	// implement in plugins written in languages other than Go. See
+	// JSONRPCPlugin.
+	ProtocolJSONRPC Protocol = "jsonrpc"
 )
 
 // ServerProtocol is an interface that must be implemented for new plugin
@@ -32,6 +44,9 @@ type ServerProtocol interface {
 
 	// Serve is called to serve connections on the given listener. This should
 	// continue until the listener is closed.
+	//
+	// If the server also has a Stop() method, it is called when serving is
+	// cancelled, after the listener is closed.
 	Serve(net.Listener)
 }
 
@@ -46,3 +61,165 @@ type ClientProtocol interface {
 	// Ping checks that the client connection is still healthy.
 	Ping() error
 }
+
+// ContextDispenser is implemented by ClientProtocols that can dispense
+// plugins with a context. See Client.DispenseContext.
+type ContextDispenser interface {
+	// DispenseContext is Dispense, bounded by ctx.
+	DispenseContext(ctx context.Context, name string) (interface{}, error)
+}
+
+var (
+	_ ContextDispenser = (*RPCClient)(nil)
+	_ ContextDispenser = (*GRPCClient)(nil)
+	_ ContextDispenser = (*JSONRPCClient)(nil)
+)
+
+// ServerProtocolConfig is passed to a ServerProtocolFactory to build the
+// server side of a protocol.
+type ServerProtocolConfig struct {
+	// Plugins is the set of plugins negotiated with the host.
+	Plugins PluginSet
+
+	// Listener is the listener that will be passed to ServerProtocol.Serve.
+	// The factory may replace it, for example to wrap it with TLS.
+	Listener net.Listener
+
+	// TLS is the TLS configuration to serve with, or nil if TLS is not in
+	// use. The factory is responsible for applying it.
+	TLS *tls.Config
+
+	// Stdout and Stderr carry the plugin's output, to be forwarded to the
+	// host.
+	Stdout io.Reader
+	Stderr io.Reader
+
+	// DoneCh must be closed by the server once the host asks the plugin to
+	// exit.
+	DoneCh chan struct{}
+
+	// Logger is the plugin's logger.
+	Logger hclog.Logger
+
+	// ServeConfig is the configuration the plugin is being served with.
+	ServeConfig *ServeConfig
+}
+
+// ServerProtocolFactory creates the server side of a protocol.
+type ServerProtocolFactory func(*ServerProtocolConfig) (ServerProtocol, error)
+
+// ClientProtocolConfig is passed to a ClientProtocolFactory to build the
+// client side of a protocol.
+type ClientProtocolConfig struct {
+	// Addr is the address the plugin is listening on.
+	Addr net.Addr
+
+	// Dial connects to the plugin. It should be preferred over dialling Addr
+	// directly, since it takes custom runners into account.
+	Dial func(context.Context) (net.Conn, error)
+
+	// Context is the context the client is created with, such as the one
+	// passed to Client.ClientContext. It can bound connecting to the plugin,
+	// but must not be kept by the client, which lives until DoneCtx is
+	// cancelled.
+	Context context.Context
+
+	// TLSConfig is the TLS configuration to connect with, or nil if TLS is
+	// not in use. The factory is responsible for applying it.
+	TLSConfig *tls.Config
+
+	// Plugins are the plugins that can be dispensed.
+	Plugins map[string]Plugin
+
+	// SyncStdout and SyncStderr receive the plugin's output, if the protocol
+	// supports forwarding it.
+	SyncStdout io.Writer
+	SyncStderr io.Writer
+
+	// Logger is the client's logger.
+	Logger hclog.Logger
+
+	// DoneCtx is cancelled once the plugin has exited.
+	DoneCtx context.Context
+
+	client *Client
+}
+
+// ClientProtocolFactory creates the client side of a protocol.
+type ClientProtocolFactory func(*ClientProtocolConfig) (ClientProtocol, error)
+
+var (
+	protocolsLock   sync.RWMutex
+	serverProtocols = make(map[Protocol]ServerProtocolFactory)
+	clientProtocols = make(map[Protocol]ClientProtocolFactory)
+)
+
+func init() {
+	RegisterServerProtocol(ProtocolNetRPC, newRPCServerProtocol)
+	RegisterServerProtocol(ProtocolGRPC, newGRPCServerProtocol)
+	RegisterServerProtocol(ProtocolJSONRPC, newJSONRPCServerProtocol)
+	RegisterClientProtocol(ProtocolNetRPC, newRPCClientProtocol)
+	RegisterClientProtocol(ProtocolGRPC, newGRPCClientProtocol)
+	RegisterClientProtocol(ProtocolJSONRPC, newJSONRPCClientProtocol)
+}
+
+// RegisterServerProtocol makes a protocol available to plugins. A plugin
+// selects it by setting ServeConfig.Protocol, and hosts must list it in
+// ClientConfig.AllowedProtocols and register a matching client with
+// RegisterClientProtocol.
+//
+// Protocols are usually registered from an init function. If a protocol is
+// registered twice, RegisterServerProtocol panics.
+func RegisterServerProtocol(p Protocol, factory ServerProtocolFactory) {
+	protocolsLock.Lock()
+	defer protocolsLock.Unlock()
+
+	if p == ProtocolInvalid || factory == nil {
+		panic("plugin: invalid server protocol registration")
+	}
+	if _, ok := serverProtocols[p]; ok {
+		panic(fmt.Sprintf("plugin: server protocol %q registered twice", p))
+	}
+	serverProtocols[p] = factory
+}
+
+// RegisterClientProtocol makes a protocol available to hosts. See
+// RegisterServerProtocol.
+//
+// Protocols are usually registered from an init function. If a protocol is
+// registered twice, RegisterClientProtocol panics.
+func RegisterClientProtocol(p Protocol, factory ClientProtocolFactory) {
+	protocolsLock.Lock()
+	defer protocolsLock.Unlock()
+
+	if p == ProtocolInvalid || factory == nil {
+		panic("plugin: invalid client protocol registration")
+	}
+	if _, ok := clientProtocols[p]; ok {
+		panic(fmt.Sprintf("plugin: client protocol %q registered twice", p))
+	}
+	clientProtocols[p] = factory
+}
+
+func serverProtocolFactory(p Protocol) (ServerProtocolFactory, bool) {
+	protocolsLock.RLock()
+	defer protocolsLock.RUnlock()
+	f, ok := serverProtocols[p]
+	return f, ok
+}
+
+func clientProtocolFactory(p Protocol) (ClientProtocolFactory, bool) {
+	protocolsLock.RLock()
+	defer protocolsLock.RUnlock()
+	f, ok := clientProtocols[p]
+	return f, ok
+}
+
+// joinProtocols formats protocols as a comma-separated list.
+func joinProtocols(protocols []Protocol) string {
+	parts := make([]string, len(protocols))
+	for i, p := range protocols {
+		parts[i] = string(p)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,160 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// testCustomProtocol is net/rpc registered under another name, built only
+// from what the registry exposes to third-party protocols.
+const testCustomProtocol Protocol = "test-custom"
+
+func init() {
+	RegisterServerProtocol(testCustomProtocol, func(cfg *ServerProtocolConfig) (ServerProtocol, error) {
+		return &RPCServer{
+			Plugins: cfg.Plugins,
+			Stdout:  cfg.Stdout,
+			Stderr:  cfg.Stderr,
+			DoneCh:  cfg.DoneCh,
+		}, nil
+	})
+	RegisterClientProtocol(testCustomProtocol, func(cfg *ClientProtocolConfig) (ClientProtocol, error) {
+		conn, err := cfg.Dial(context.Background())
+		if err != nil {
+			return nil, err
+		}
+
+		client, err := NewRPCClient(conn, cfg.Plugins)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.SyncStreams(cfg.SyncStdout, cfg.SyncStderr); err != nil {
+			_ = client.Close()
+			return nil, err
+		}
+
+		return client, nil
+	})
+}
+
+func TestClient_customProtocol(t *testing.T) {
+	process := helperProcess("test-custom-protocol")
+	c := NewClient(&ClientConfig{
+		Cmd:              process,
+		HandshakeConfig:  testHandshake,
+		Plugins:          testPluginMap,
+		AllowedProtocols: []Protocol{testCustomProtocol},
+	})
+	defer c.Kill()
+
+	if _, err := c.Start(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if v := c.Protocol(); v != testCustomProtocol {
+		t.Fatalf("bad: %s", v)
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	raw, err := client.Dispense("test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n := raw.(testInterface).Double(21); n != 42 {
+		t.Fatalf("bad: %d", n)
+	}
+}
+
+func TestClient_customProtocolNotAllowed(t *testing.T) {
+	process := helperProcess("test-custom-protocol")
+	c := NewClient(&ClientConfig{
+		Cmd:             process,
+		HandshakeConfig: testHandshake,
+		Plugins:         testPluginMap,
+	})
+	defer c.Kill()
+
+	_, err := c.Start()
+	if err == nil || !strings.Contains(err.Error(), string(testCustomProtocol)) {
+		t.Fatalf("expected protocol error, got: %v", err)
+	}
+}
+
+func TestRegisterServerProtocol_duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("should panic")
+		}
+	}()
+
+	RegisterServerProtocol(ProtocolGRPC, newGRPCServerProtocol)
+}
+
+func TestClient_protocolPreference(t *testing.T) {
+	for _, expected := range []Protocol{ProtocolNetRPC, ProtocolGRPC} {
+		t.Run(string(expected), func(t *testing.T) {
+			// Prefer the expected protocol, but allow both.
+			allowed := []Protocol{ProtocolNetRPC, ProtocolGRPC}
+			if expected == ProtocolGRPC {
+				allowed = []Protocol{ProtocolGRPC, ProtocolNetRPC}
+			}
+
+			process := helperProcess("test-protocols")
+			c := NewClient(&ClientConfig{
+				Cmd:              process,
+				HandshakeConfig:  testHandshake,
+				Plugins:          testGRPCPluginMap,
+				AllowedProtocols: allowed,
+			})
+			defer c.Kill()
+
+			client, err := c.Client()
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if v := c.Protocol(); v != expected {
+				t.Fatalf("expected %s, got %s", expected, v)
+			}
+
+			raw, err := client.Dispense("test")
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if n := raw.(testInterface).Double(21); n != 42 {
+				t.Fatalf("bad: %d", n)
+			}
+		})
+	}
+}
+
+func TestNegotiateProtocol(t *testing.T) {
+	cases := []struct {
+		name      string
+		host      string
+		protocols []Protocol
+		expected  Protocol
+	}{
+		{"not advertised", "grpc,netrpc", nil, ProtocolGRPC},
+		{"host preference", "netrpc,grpc", []Protocol{ProtocolGRPC, ProtocolNetRPC}, ProtocolNetRPC},
+		{"skip unsupported", "jsonrpc,grpc", []Protocol{ProtocolGRPC, ProtocolNetRPC}, ProtocolGRPC},
+		{"legacy host", "", []Protocol{ProtocolGRPC, ProtocolNetRPC}, ProtocolNetRPC},
+		{"legacy host without netrpc", "", []Protocol{ProtocolJSONRPC, ProtocolGRPC}, ProtocolJSONRPC},
+		{"no overlap", "jsonrpc", []Protocol{ProtocolNetRPC}, ProtocolGRPC},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(envPluginProtocols, tc.host)
+			actual := negotiateProtocol(&ServeConfig{Protocols: tc.protocols}, ProtocolGRPC)
+			if actual != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+)
+
+// reattachConfigJSON is the JSON representation of a ReattachConfig. The
+// layout matches the format long used by Terraform's TF_REATTACH_PROVIDERS,
+// so existing tooling producing that format can be consumed as-is.
+type reattachConfigJSON struct {
+	Protocol        Protocol
+	ProtocolVersion int
+	Addr            reattachAddrJSON
+	Pid             int
+	Test            bool
+	TLS             *ReattachTLSConfig `json:",omitempty"`
+
+	GRPCBrokerMultiplex bool `json:",omitempty"`
+}
+
+type reattachAddrJSON struct {
+	Network string
+	String  string
+}
+
+// MarshalJSON implements json.Marshaler. ReattachFunc cannot be serialized
+// and is always omitted.
+func (c ReattachConfig) MarshalJSON() ([]byte, error) {
+	out := reattachConfigJSON{
+		Protocol:        c.Protocol,
+		ProtocolVersion: c.ProtocolVersion,
+		Pid:             c.Pid,
+		Test:            c.Test,
+		TLS:             c.TLS,
+
+		GRPCBrokerMultiplex: c.GRPCBrokerMultiplex,
+	}
+	if c.Addr != nil {
+		out.Addr = reattachAddrJSON{
+			Network: c.Addr.Network(),
+			String:  c.Addr.String(),
+		}
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (c *ReattachConfig) UnmarshalJSON(data []byte) error {
+	var in reattachConfigJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	var addr net.Addr
+	var err error
+	switch in.Addr.Network {
+	case "":
+	case "tcp":
+		addr, err = net.ResolveTCPAddr("tcp", in.Addr.String)
+	case "unix":
+		addr, err = net.ResolveUnixAddr("unix", in.Addr.String)
+	default:
+		// Other networks can only be dialled by a custom ReattachFunc, so we
+		// keep the address as an opaque value for it to interpret.
+		addr = &reattachAddr{network: in.Addr.Network, address: in.Addr.String}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid reattach address %q: %w", in.Addr.String, err)
+	}
+
+	*c = ReattachConfig{
+		Protocol:        in.Protocol,
+		ProtocolVersion: in.ProtocolVersion,
+		Addr:            addr,
+		Pid:             in.Pid,
+		Test:            in.Test,
+		TLS:             in.TLS,
+
+		GRPCBrokerMultiplex: in.GRPCBrokerMultiplex,
+	}
+	return nil
+}
+
+// reattachAddr is a net.Addr for networks that the net package can't
+// resolve on its own.
+type reattachAddr struct {
+	network string
+	address string
+}
+
+func (a *reattachAddr) Network() string { return a.network }
+func (a *reattachAddr) String() string  { return a.address }
+
+// ReattachConfigsFromEnv reads the EnvReattachPlugins environment variable,
+// which holds a JSON object mapping plugin names to reattach configurations.
+// If the environment variable is not set, it returns a nil map.
+//
+// Hosts normally don't need to call this directly; see
+// ClientConfig.ReattachName.
+func ReattachConfigsFromEnv() (map[string]*ReattachConfig, error) {
+	raw := os.Getenv(EnvReattachPlugins)
+	if raw == "" {
+		return nil, nil
+	}
+
+	var configs map[string]*ReattachConfig
+	if err := json.Unmarshal([]byte(raw), &configs); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", EnvReattachPlugins, err)
+	}
+
+	return configs, nil
+}
@@ -0,0 +1,214 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+func TestReattachConfig_json(t *testing.T) {
+	tcpAddr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unixAddr, err := net.ResolveUnixAddr("unix", "/tmp/plugin123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, config := range map[string]*ReattachConfig{
+		"tcp": {
+			Protocol:        ProtocolGRPC,
+			ProtocolVersion: 5,
+			Addr:            tcpAddr,
+			Pid:             42,
+			Test:            true,
+		},
+		"multiplexed": {
+			Protocol:            ProtocolGRPC,
+			ProtocolVersion:     5,
+			Addr:                unixAddr,
+			Pid:                 42,
+			GRPCBrokerMultiplex: true,
+		},
+		"unix with tls": {
+			Protocol: ProtocolNetRPC,
+			Addr:     unixAddr,
+			Pid:      42,
+			TLS: &ReattachTLSConfig{
+				ClientCert: []byte("cert"),
+				ClientKey:  []byte("key"),
+				ServerCert: []byte("server"),
+			},
+		},
+		"custom network": {
+			Protocol: ProtocolGRPC,
+			Addr:     &reattachAddr{network: "vsock", address: "3:1234"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := json.Marshal(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var actual ReattachConfig
+			if err := json.Unmarshal(data, &actual); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(config, &actual) {
+				t.Fatalf("expected %#v, got %#v", config, &actual)
+			}
+		})
+	}
+}
+
+func TestReattachConfig_unmarshalTerraformFormat(t *testing.T) {
+	raw := `{"Protocol":"grpc","ProtocolVersion":5,"Pid":1234,"Test":true,"Addr":{"Network":"unix","String":"/tmp/plugin123"}}`
+
+	var config ReattachConfig
+	if err := json.Unmarshal([]byte(raw), &config); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Protocol != ProtocolGRPC || config.ProtocolVersion != 5 || config.Pid != 1234 || !config.Test {
+		t.Fatalf("bad: %#v", config)
+	}
+	if _, ok := config.Addr.(*net.UnixAddr); !ok {
+		t.Fatalf("expected unix address, got %#v", config.Addr)
+	}
+	if config.Addr.String() != "/tmp/plugin123" {
+		t.Fatalf("bad address: %s", config.Addr)
+	}
+}
+
+func TestReattachConfigsFromEnv(t *testing.T) {
+	t.Setenv(EnvReattachPlugins, "")
+	configs, err := ReattachConfigsFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configs != nil {
+		t.Fatalf("expected nil, got %#v", configs)
+	}
+
+	t.Setenv(EnvReattachPlugins, `{"foo":{"Protocol":"grpc","Pid":1,"Addr":{"Network":"tcp","String":"127.0.0.1:1234"}}}`)
+	configs, err = ReattachConfigsFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 1 || configs["foo"] == nil || configs["foo"].Addr.String() != "127.0.0.1:1234" {
+		t.Fatalf("bad: %#v", configs)
+	}
+
+	t.Setenv(EnvReattachPlugins, `not json`)
+	if _, err := ReattachConfigsFromEnv(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestClient_reattachName(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan *ReattachConfig, 1)
+	closeCh := make(chan struct{})
+	go Serve(&ServeConfig{
+		HandshakeConfig: testHandshake,
+		Plugins:         testGRPCPluginMap,
+		GRPCServer:      DefaultGRPCServer,
+		Logger:          hclog.NewNullLogger(),
+		Test: &ServeTestConfig{
+			Context:          ctx,
+			ReattachConfigCh: ch,
+			CloseCh:          closeCh,
+		},
+	})
+
+	var config *ReattachConfig
+	select {
+	case config = <-ch:
+	case <-time.After(2000 * time.Millisecond):
+		t.Fatal("should've received reattach")
+	}
+
+	data, err := json.Marshal(map[string]*ReattachConfig{"test": config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvReattachPlugins, string(data))
+
+	// Cmd would fail to start, so a working client proves we reattached.
+	c := NewClient(&ClientConfig{
+		Cmd:              exec.Command("/nonexistent/plugin"),
+		ReattachName:     "test",
+		HandshakeConfig:  testHandshake,
+		Plugins:          testGRPCPluginMap,
+		AllowedProtocols: []Protocol{ProtocolGRPC},
+	})
+	defer c.Kill()
+
+	client, err := c.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := client.Ping(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// An unknown name falls back to Cmd
+	c2 := NewClient(&ClientConfig{
+		Cmd:             exec.Command("/nonexistent/plugin"),
+		ReattachName:    "other",
+		HandshakeConfig: testHandshake,
+		Plugins:         testGRPCPluginMap,
+	})
+	defer c2.Kill()
+	if _, err := c2.Start(); err == nil {
+		t.Fatal("expected error starting nonexistent command")
+	}
+
+	cancel()
+	<-closeCh
+}
+
+func TestClient_ReattachConfig_multiplexStreamIDs(t *testing.T) {
+	addr, err := net.ResolveUnixAddr("unix", "/tmp/plugin123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		grpcMux, streamIDs bool
+		expected           bool
+	}{
+		"not multiplexed":             {false, false, false},
+		"multiplexed with stream IDs": {true, true, true},
+		// Older plugins multiplex without stream IDs, and can't be
+		// reattached to with multiplexing.
+		"multiplexed without stream IDs": {true, false, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := &Client{
+				config:           &ClientConfig{},
+				address:          addr,
+				protocol:         ProtocolGRPC,
+				grpcMux:          tc.grpcMux,
+				grpcMuxStreamIDs: tc.streamIDs,
+			}
+			if actual := c.ReattachConfig().GRPCBrokerMultiplex; actual != tc.expected {
+				t.Fatalf("expected GRPCBrokerMultiplex %t, got %t", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@
 package plugin
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
@@ -21,13 +22,30 @@ type RPCClient struct {
 
 	// These are the streams used for the various stdout/err overrides
 	stdout, stderr net.Conn
+
+	// checkExited classifies the errors of the RPC connections, if set.
+	checkExited func(error) error
+
+	// idle tracks the calls to the dispensed plugins, if set.
+	idle *idleTracker
+}
+
+// newRPCClientProtocol is the ClientProtocolFactory for ProtocolNetRPC.
+func newRPCClientProtocol(cfg *ClientProtocolConfig) (ClientProtocol, error) {
+	client, err := newRPCClient(cfg.Context, cfg.client)
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
 }
 
 // newRPCClient creates a new RPCClient. The Client argument is expected
 // to be successfully started already with a lock held.
-func newRPCClient(c *Client) (*RPCClient, error) {
+func newRPCClient(ctx context.Context, c *Client) (*RPCClient, error) {
 	// Connect to the client
-	conn, err := net.Dial(c.address.Network(), c.address.String())
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, c.address.Network(), c.address.String())
 	if err != nil {
 		return nil, err
 	}
@@ -36,16 +54,21 @@ func newRPCClient(c *Client) (*RPCClient, error) {
 		_ = tcpConn.SetKeepAlive(true)
 	}
 
+	// Calls failing because the plugin exited return a PluginExitedError.
+	exits := newExitChecker(c.doneCtx)
+	conn = exits.wrapConn(conn)
+
 	if c.config.TLSConfig != nil {
 		conn = tls.Client(conn, c.config.TLSConfig)
 	}
 
 	// Create the actual RPC client
-	result, err := NewRPCClient(conn, c.config.Plugins)
+	result, err := newRPCClientConn(conn, c.config.Plugins, c.config.Broker, exits.check)
 	if err != nil {
 		_ = conn.Close()
 		return nil, err
 	}
+	result.idle = c.idle
 
 	// Begin the stream syncing so that stdin, out, err work properly
 	err = result.SyncStreams(
@@ -62,6 +85,12 @@ func newRPCClient(c *Client) (*RPCClient, error) {
 // NewRPCClient creates a client from an already-open connection-like value.
 // Dial is typically used instead.
 func NewRPCClient(conn io.ReadWriteCloser, plugins map[string]Plugin) (*RPCClient, error) {
+	return newRPCClientConn(conn, plugins, nil, nil)
+}
+
+// newRPCClientConn is NewRPCClient with a configured broker. If checkExited
+// is not nil, it classifies the errors of the RPC connections.
+func newRPCClientConn(conn io.ReadWriteCloser, plugins map[string]Plugin, brokerConfig *BrokerConfig, checkExited func(error) error) (*RPCClient, error) {
 	// Create the yamux client so we can multiplex
 	mux, err := yamux.Client(conn, nil)
 	if err != nil {
@@ -87,17 +116,19 @@ func NewRPCClient(conn io.ReadWriteCloser, plugins map[string]Plugin) (*RPCClien
 	}
 
 	// Create the broker and start it up
-	broker := newMuxBroker(mux)
+	broker := newMuxBroker(mux, brokerConfig)
 	go broker.Run()
 
 	// Build the client using our broker and control channel.
-	return &RPCClient{
-		broker:  broker,
-		control: rpc.NewClient(control),
-		plugins: plugins,
-		stdout:  stdstream[0],
-		stderr:  stdstream[1],
-	}, nil
+	c := &RPCClient{
+		broker:      broker,
+		plugins:     plugins,
+		stdout:      stdstream[0],
+		stderr:      stdstream[1],
+		checkExited: checkExited,
+	}
+	c.control = c.rpcClientFor(control, nil)
+	return c, nil
 }
 
 // SyncStreams should be called to enable syncing of stdout,
@@ -143,15 +174,28 @@ func (c *RPCClient) Close() error {
 }
 
 func (c *RPCClient) Dispense(name string) (interface{}, error) {
+	return c.DispenseContext(context.Background(), name)
+}
+
+// DispenseContext is Dispense, but gives up waiting for the plugin once ctx
+// is done.
+func (c *RPCClient) DispenseContext(ctx context.Context, name string) (interface{}, error) {
 	p, ok := c.plugins[name]
 	if !ok {
 		return nil, fmt.Errorf("unknown plugin type: %s", name)
 	}
 
 	var id uint32
-	if err := c.control.Call(
-		"Dispenser.Dispense", name, &id); err != nil {
-		return nil, err
+	call := c.control.Go("Dispenser.Dispense", name, &id, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		if call.Error != nil {
+			return nil, call.Error
+		}
+	case <-ctx.Done():
+		// The plugin stops waiting for the connection after the broker
+		// timeout.
+		return nil, ctx.Err()
 	}
 
 	conn, err := c.broker.Dial(id)
@@ -159,7 +203,26 @@ func (c *RPCClient) Dispense(name string) (interface{}, error) {
 		return nil, err
 	}
 
-	return p.Client(c.broker, rpc.NewClient(conn))
+	return p.Client(c.broker, c.rpcClientFor(conn, c.idle))
+}
+
+// rpcClientFor returns a net/rpc client for conn. If idle is not nil, it
+// tracks the calls of the client.
+func (c *RPCClient) rpcClientFor(conn net.Conn, idle *idleTracker) *rpc.Client {
+	return newPluginRPCClient(conn, c.checkExited, idle)
+}
+
+// newPluginRPCClient returns a net/rpc client for a connection to the
+// plugin. If checkExited is not nil, it classifies the errors of the
+// connection, and if idle is not nil, it tracks the calls of the client.
+func newPluginRPCClient(conn net.Conn, checkExited func(error) error, idle *idleTracker) *rpc.Client {
+	if checkExited != nil {
+		conn = &exitedConn{Conn: conn, check: checkExited}
+	}
+	if idle != nil {
+		return rpc.NewClientWithCodec(newIdleClientCodec(conn, idle))
+	}
+	return rpc.NewClient(conn)
 }
 
 // Ping pings the connection to ensure it is still alive.
@@ -4,6 +4,7 @@
 package plugin
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -34,9 +35,39 @@ type RPCServer struct {
 	// when the control requests the RPC server to end.
 	DoneCh chan<- struct{}
 
+	// persistent is set in debug mode, where the server keeps running when
+	// a client asks it to quit.
+	persistent bool
+
+	// brokerConfig configures the MuxBroker of each connection.
+	brokerConfig *BrokerConfig
+
 	lock sync.Mutex
 }
 
+// newRPCServerProtocol is the ServerProtocolFactory for ProtocolNetRPC.
+func newRPCServerProtocol(cfg *ServerProtocolConfig) (ServerProtocol, error) {
+	// If we have a TLS configuration then we wrap the listener
+	// ourselves and do it at that level.
+	if cfg.TLS != nil {
+		cfg.Listener = tls.NewListener(cfg.Listener, cfg.TLS)
+	}
+
+	server := &RPCServer{
+		Plugins: cfg.Plugins,
+		Stdout:  cfg.Stdout,
+		Stderr:  cfg.Stderr,
+		DoneCh:  cfg.DoneCh,
+
+		persistent: cfg.ServeConfig != nil && cfg.ServeConfig.Debug != nil,
+	}
+	if cfg.ServeConfig != nil {
+		server.brokerConfig = cfg.ServeConfig.Broker
+	}
+
+	return server, nil
+}
+
 // ServerProtocol impl.
 func (s *RPCServer) Init() error { return nil }
 
@@ -101,7 +132,7 @@ func (s *RPCServer) ServeConn(conn io.ReadWriteCloser) {
 	go copyStream("stderr", stdstream[1], s.Stderr)
 
 	// Create the broker and start it up
-	broker := newMuxBroker(mux)
+	broker := newMuxBroker(mux, s.brokerConfig)
 	go broker.Run()
 
 	// Use the control connection to build the dispenser and serve the
@@ -147,8 +178,10 @@ func (c *controlServer) Ping(
 func (c *controlServer) Quit(
 	null bool, response *struct{},
 ) error {
-	// End the server
-	c.server.done()
+	// End the server, unless it should outlive its clients
+	if !c.server.persistent {
+		c.server.done()
+	}
 
 	// Always return true
 	*response = struct{}{}
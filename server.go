@@ -8,6 +8,8 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
@@ -16,12 +18,12 @@ import (
 	"os/signal"
 	"os/user"
 	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 
 	hclog "github.com/hashicorp/go-hclog"
-	"github.com/hashicorp/go-plugin/internal/grpcmux"
 	"google.golang.org/grpc"
 )
 
@@ -32,6 +34,10 @@ import (
 // infrequently.
 const CoreProtocolVersion = 1
 
+// ErrNotSocketActivated is returned by ActivationListener when the process
+// was not started with a listening socket by a service manager.
+var ErrNotSocketActivated = errors.New("process was not socket activated")
+
 // HandshakeConfig is the configuration used by client and servers to
 // handshake before starting a plugin connection. This is embedded by
 // both ServeConfig and ClientConfig.
@@ -85,10 +91,45 @@ type ServeConfig struct {
 	// relies on this to implement Ping().
 	GRPCServer func([]grpc.ServerOption) *grpc.Server
 
+	// Protocol, if set, is the protocol to serve the plugins over instead of
+	// the one selected from the plugin types. It must have been registered
+	// with RegisterServerProtocol, and the host must allow it.
+	Protocol Protocol
+
+	// Protocols, if set, lists every protocol the plugins can be served
+	// over, and lets the host choose between them: the first protocol in
+	// the host's ClientConfig.AllowedProtocols that is listed here is used.
+	// The plugins must implement the interfaces of all the listed protocols,
+	// such as both Plugin and GRPCPlugin. If gRPC is chosen, GRPCServer is
+	// used to create the server, or DefaultGRPCServer if it is nil.
+	//
+	// Hosts using older versions of go-plugin don't send their preferences.
+	// They are served net/rpc if it is listed, since that's all they accept
+	// by default, and otherwise the first listed protocol. If there is no
+	// protocol in common, Protocol or the protocol selected from the plugin
+	// types is used, and the host will report the mismatch.
+	Protocols []Protocol
+
 	// Logger is used to pass a logger into the server. If none is provided the
 	// server will create a default logger.
 	Logger hclog.Logger
 
+	// Stdout and Stderr are where the server writes the handshake and any
+	// diagnostic messages. If these are nil, os.Stdout and os.Stderr are
+	// used. The default logger also writes to Stderr.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Listener, if set, is used to accept connections from the host instead
+	// of a listener created by go-plugin. It is closed when serving ends.
+	// Plugins started by a service manager can set it to the socket returned
+	// by ActivationListener.
+	Listener net.Listener
+
+	// Broker configures the plugin's side of the MuxBroker or GRPCBroker,
+	// such as how long it waits for the host. If nil, defaults are used.
+	Broker *BrokerConfig
+
 	// Test, if non-nil, will put plugin serving into "test mode". This is
 	// meant to be used as part of `go test` within a plugin's codebase to
 	// launch the plugin in-process and output a ReattachConfig.
@@ -101,6 +142,42 @@ type ServeConfig struct {
 	//   * Connection information will not be sent to stdout
 	//
 	Test *ServeTestConfig
+
+	// Debug, if non-nil, will put plugin serving into "debug mode". This is
+	// meant for running the plugin binary directly, for example under a
+	// debugger such as delve, and having hosts reattach to it.
+	//
+	// This changes the behavior of the server in a number of ways:
+	//
+	//   * The handshake cookie is not validated.
+	//   * A reattach configuration is printed to stdout as an
+	//     EnvReattachPlugins environment variable that can be copied into
+	//     the host's environment (see ClientConfig.ReattachName).
+	//   * Requests from hosts to shut down are ignored, so the plugin keeps
+	//     serving across multiple host connections until it is interrupted
+	//     (Ctrl-C) or Debug.Context is cancelled. Only one host can use
+	//     the gRPC broker at a time.
+	//   * Stdout/stderr are not redirected to the host, and the default
+	//     logger writes human-readable output to the terminal.
+	//
+	Debug *ServeDebugConfig
+}
+
+// ServeDebugConfig configures plugin serving for debug mode. See
+// ServeConfig.Debug.
+type ServeDebugConfig struct {
+	// Name is the key the plugin is listed under in the printed reattach
+	// configuration. It should match the host's ClientConfig.ReattachName.
+	Name string
+
+	// Context, if set, will end plugin serving when cancelled, in addition
+	// to an interrupt signal.
+	Context context.Context
+
+	// GRPCBrokerMultiplex, if true, multiplexes the gRPC broker as hosts
+	// with ClientConfig.GRPCBrokerMultiplex would ask for. It is set in the
+	// printed reattach configuration.
+	GRPCBrokerMultiplex bool
 }
 
 // ServeTestConfig configures plugin serving for test mode. See ServeConfig.Test.
@@ -133,6 +210,17 @@ type ServeTestConfig struct {
 	// and SyncStdio functionality is fairly rare, so we default to the simple
 	// scenario.
 	SyncStdio bool
+
+	// AutoMTLS, if true, makes the server generate mTLS certificates for
+	// both itself and its client, as ClientConfig.AutoMTLS would. The
+	// client's half is sent back in ReattachConfig.TLS so that reattaching
+	// clients connect over mTLS. It is ignored if TLSProvider is set.
+	AutoMTLS bool
+
+	// GRPCBrokerMultiplex, if true, multiplexes the gRPC broker as hosts
+	// with ClientConfig.GRPCBrokerMultiplex would ask for. It is set in
+	// ReattachConfig.GRPCBrokerMultiplex.
+	GRPCBrokerMultiplex bool
 }
 
 func unixSocketConfigFromEnv() UnixSocketConfig {
@@ -196,7 +284,7 @@ func protocolVersion(opts *ServeConfig) (int, Protocol, PluginSet) {
 			// for the protocol type
 			for _, p := range pluginSet {
 				switch p.(type) {
-				case GRPCPlugin:
+				case GRPCPlugin, netRPCBridgedPlugin:
 					protoType = ProtocolGRPC
 				default:
 					protoType = ProtocolNetRPC
@@ -221,6 +309,33 @@ func protocolVersion(opts *ServeConfig) (int, Protocol, PluginSet) {
 	return protoVersion, protoType, pluginSet
 }
 
+// negotiateProtocol picks the protocol to serve the plugins over, given the
+// protocol selected by protocolVersion. See ServeConfig.Protocols.
+func negotiateProtocol(opts *ServeConfig, selected Protocol) Protocol {
+	if opts.Protocol != ProtocolInvalid {
+		selected = opts.Protocol
+	}
+	if len(opts.Protocols) == 0 {
+		return selected
+	}
+
+	hostProtocols := os.Getenv(envPluginProtocols)
+	if hostProtocols == "" {
+		if slices.Contains(opts.Protocols, ProtocolNetRPC) {
+			return ProtocolNetRPC
+		}
+		return opts.Protocols[0]
+	}
+
+	for _, p := range strings.Split(hostProtocols, ",") {
+		if slices.Contains(opts.Protocols, Protocol(p)) {
+			return Protocol(p)
+		}
+	}
+
+	return selected
+}
+
 // Serve serves the plugins given by ServeConfig.
 //
 // Serve doesn't return until the plugin is done being executed. Any
@@ -230,61 +345,113 @@ func protocolVersion(opts *ServeConfig) (int, Protocol, PluginSet) {
 //
 // This is the method that plugins should call in their main() functions.
 func Serve(opts *ServeConfig) {
-	exitCode := -1
-	// We use this to trigger an `os.Exit` so that we can execute our other
-	// deferred functions. In test mode, we just output the err to stderr
-	// and return.
-	defer func() {
-		if opts.Test == nil && exitCode >= 0 {
-			os.Exit(exitCode)
-		}
+	err := runServe(context.Background(), opts, true)
+
+	// Other errors are logged, and Serve just returns.
+	var serveErr *serveError
+	if !errors.As(err, &serveErr) {
+		return
+	}
+	if serveErr.panic {
+		panic(serveErr.err)
+	}
+	os.Exit(1)
+}
+
+// serveError is an error of runServe that Serve doesn't return from. If
+// panic is false, Serve exits with a status code of 1, and otherwise it
+// panics.
+type serveError struct {
+	err   error
+	panic bool
+}
+
+func (e *serveError) Error() string {
+	return e.err.Error()
+}
+
+func (e *serveError) Unwrap() error {
+	return e.err
+}
 
+// ServeContext is like Serve, but is meant for running go-plugin as one
+// component of a larger program, and for testing.
+//
+// ServeContext serves until the host asks the plugin to exit or ctx is
+// cancelled, and reports any failure as an error. It never exits the
+// process or panics, doesn't ignore interrupts like Serve does, and leaves
+// os.Stdout and os.Stderr untouched. In debug mode it still stops serving
+// on an interrupt, like Serve. The handshake is written to
+// ServeConfig.Stdout, and diagnostics to ServeConfig.Stderr. Because stdout
+// and stderr are not redirected, the host's ClientConfig.SyncStdout and
+// SyncStderr receive no data.
+func ServeContext(ctx context.Context, opts *ServeConfig) error {
+	return runServe(ctx, opts, false)
+}
+
+// runServe implements Serve and ServeContext. If takeover is true, the plugin
+// owns the process: interrupts are ignored so that the host can manage our
+// lifecycle, and os.Stdout/os.Stderr are redirected to the host.
+func runServe(ctx context.Context, opts *ServeConfig, takeover bool) error {
+	defer func() {
 		if opts.Test != nil && opts.Test.CloseCh != nil {
 			close(opts.Test.CloseCh)
 		}
 	}()
 
-	if opts.Test == nil {
+	stdout, stderr := opts.Stdout, opts.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	if opts.Test == nil && opts.Debug == nil {
 		// Validate the handshake config
 		if opts.MagicCookieKey == "" || opts.MagicCookieValue == "" {
-			fmt.Fprintf(os.Stderr,
+			fmt.Fprintf(stderr,
 				"Misconfigured ServeConfig given to serve this plugin: no magic cookie\n"+
 					"key or value was set. Please notify the plugin author and report\n"+
 					"this as a bug.\n")
-			exitCode = 1
-			return
+			return &serveError{err: errors.New("no magic cookie key or value was set")}
 		}
 
 		// First check the cookie
 		if os.Getenv(opts.MagicCookieKey) != opts.MagicCookieValue {
-			fmt.Fprintf(os.Stderr,
+			fmt.Fprintf(stderr,
 				"This binary is a plugin. These are not meant to be executed directly.\n"+
 					"Please execute the program that consumes these plugins, which will\n"+
 					"load any plugins automatically\n")
-			exitCode = 1
-			return
+			return &serveError{err: errors.New("magic cookie did not match")}
 		}
 	}
 
 	// negotiate the version and plugins
 	// start with default version in the handshake config
 	protoVersion, protoType, pluginSet := protocolVersion(opts)
+	protoType = negotiateProtocol(opts, protoType)
 
 	logger := opts.Logger
 	if logger == nil {
-		// internal logger to os.Stderr
+		// internal logger to stderr. In debug mode nobody is parsing our
+		// stderr, so we log in a human-readable format instead.
 		logger = hclog.New(&hclog.LoggerOptions{
 			Level:      hclog.Trace,
-			Output:     os.Stderr,
-			JSONFormat: true,
+			Output:     stderr,
+			JSONFormat: opts.Debug == nil,
 		})
 	}
 
 	// Register a listener so we can accept a connection
-	listener, err := serverListener(unixSocketConfigFromEnv())
-	if err != nil {
-		logger.Error("plugin init error", "error", err)
-		return
+	listener := opts.Listener
+	if listener == nil {
+		var err error
+		listener, err = serverListener(unixSocketConfigFromEnv())
+		if err != nil {
+			logger.Error("plugin init error", "error", err)
+			return fmt.Errorf("error creating plugin listener: %w", err)
+		}
 	}
 
 	// Close the listener on return. We wrap this in a func() on purpose
@@ -295,10 +462,11 @@ func Serve(opts *ServeConfig) {
 
 	var tlsConfig *tls.Config
 	if opts.TLSProvider != nil {
+		var err error
 		tlsConfig, err = opts.TLSProvider()
 		if err != nil {
 			logger.Error("plugin tls init", "error", err)
-			return
+			return fmt.Errorf("error configuring plugin TLS: %w", err)
 		}
 	}
 
@@ -313,30 +481,46 @@ func Serve(opts *ServeConfig) {
 			logger.Error("client cert provided but failed to parse", "cert", clientCert)
 		}
 
-		certPEM, keyPEM, err := generateCert()
+		var cert tls.Certificate
+		var err error
+		tlsConfig, cert, err = generateServerTLSConfig(clientCertPool)
 		if err != nil {
 			logger.Error("failed to generate server certificate", "error", err)
-			panic(err)
+			return &serveError{err: fmt.Errorf("error generating server certificate: %w", err), panic: true}
 		}
 
-		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		// We send back the raw leaf cert data for the client rather than the
+		// PEM, since the protocol can't handle newlines.
+		serverCert = base64.RawStdEncoding.EncodeToString(cert.Certificate[0])
+	}
+
+	// In test mode there is no client to hand us its certificate, so if
+	// requested we generate the client's certificate too and send all of the
+	// material back as part of the ReattachConfig.
+	var reattachTLS *ReattachTLSConfig
+	if tlsConfig == nil && opts.Test != nil && opts.Test.AutoMTLS {
+		logger.Debug("configuring test mode automatic mTLS")
+		clientCertPEM, clientKeyPEM, err := generateCert()
 		if err != nil {
-			logger.Error("failed to parse server certificate", "error", err)
-			panic(err)
+			logger.Error("failed to generate client certificate", "error", err)
+			return fmt.Errorf("error generating client certificate: %w", err)
 		}
 
-		tlsConfig = &tls.Config{
-			Certificates: []tls.Certificate{cert},
-			ClientAuth:   tls.RequireAndVerifyClientCert,
-			ClientCAs:    clientCertPool,
-			MinVersion:   tls.VersionTLS12,
-			RootCAs:      clientCertPool,
-			ServerName:   "localhost",
+		clientCertPool := x509.NewCertPool()
+		clientCertPool.AppendCertsFromPEM(clientCertPEM)
+
+		var cert tls.Certificate
+		tlsConfig, cert, err = generateServerTLSConfig(clientCertPool)
+		if err != nil {
+			logger.Error("failed to generate server certificate", "error", err)
+			return fmt.Errorf("error generating server certificate: %w", err)
 		}
 
-		// We send back the raw leaf cert data for the client rather than the
-		// PEM, since the protocol can't handle newlines.
-		serverCert = base64.RawStdEncoding.EncodeToString(cert.Certificate[0])
+		reattachTLS = &ReattachTLSConfig{
+			ClientCert: clientCertPEM,
+			ClientKey:  clientKeyPEM,
+			ServerCert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}),
+		}
 	}
 
 	// Create the channel to tell us when we're done
@@ -344,18 +528,34 @@ func Serve(opts *ServeConfig) {
 
 	// Create our new stdout, stderr files. These will override our built-in
 	// stdout/stderr so that it works across the stream boundary.
-	var stdout_r, stderr_r io.Reader
-	stdout_r, stdout_w, err := os.Pipe()
+	stdoutPipe, stdout_w, err := os.Pipe()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error preparing plugin: %s\n", err)
-		os.Exit(1)
+		fmt.Fprintf(stderr, "Error preparing plugin: %s\n", err)
+		return &serveError{err: fmt.Errorf("error preparing plugin: %w", err)}
 	}
-	stderr_r, stderr_w, err := os.Pipe()
+	stderrPipe, stderr_w, err := os.Pipe()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error preparing plugin: %s\n", err)
-		os.Exit(1)
+		_ = stdoutPipe.Close()
+		_ = stdout_w.Close()
+		fmt.Fprintf(stderr, "Error preparing plugin: %s\n", err)
+		return &serveError{err: fmt.Errorf("error preparing plugin: %w", err)}
 	}
 
+	// Release the pipes when we're done, unless they have become the
+	// process's stdout and stderr for good.
+	keepStdio := false
+	defer func() {
+		if keepStdio {
+			return
+		}
+		_ = stdout_w.Close()
+		_ = stderr_w.Close()
+		_ = stdoutPipe.Close()
+		_ = stderrPipe.Close()
+	}()
+
+	var stdout_r, stderr_r io.Reader = stdoutPipe, stderrPipe
+
 	// If we're in test mode, we tee off the reader and write the data
 	// as-is to our normal Stdout and Stderr so that they continue working
 	// while stdio works. This is because in test mode, we assume we're running
@@ -365,63 +565,63 @@ func Serve(opts *ServeConfig) {
 		// TODO(mitchellh): This isn't super ideal because a TeeReader
 		// only works if the reader side is actively read. If we never
 		// connect via a plugin client, the output still gets swallowed.
-		stdout_r = io.TeeReader(stdout_r, os.Stdout)
-		stderr_r = io.TeeReader(stderr_r, os.Stderr)
+		stdout_r = io.TeeReader(stdout_r, stdout)
+		stderr_r = io.TeeReader(stderr_r, stderr)
 	}
 
 	// Build the server type
-	var server ServerProtocol
-	switch protoType {
-	case ProtocolNetRPC:
-		// If we have a TLS configuration then we wrap the listener
-		// ourselves and do it at that level.
-		if tlsConfig != nil {
-			listener = tls.NewListener(listener, tlsConfig)
-		}
-
-		// Create the RPC server to dispense
-		server = &RPCServer{
-			Plugins: pluginSet,
-			Stdout:  stdout_r,
-			Stderr:  stderr_r,
-			DoneCh:  doneCh,
-		}
-
-	case ProtocolGRPC:
-		var muxer *grpcmux.GRPCServerMuxer
-		if multiplex, _ := strconv.ParseBool(os.Getenv(envMultiplexGRPC)); multiplex {
-			muxer = grpcmux.NewGRPCServerMuxer(logger, listener)
-			listener = muxer
-		}
-
-		// Create the gRPC server
-		server = &GRPCServer{
-			Plugins: pluginSet,
-			Server:  opts.GRPCServer,
-			TLS:     tlsConfig,
-			Stdout:  stdout_r,
-			Stderr:  stderr_r,
-			DoneCh:  doneCh,
-			logger:  logger,
-			muxer:   muxer,
-		}
-
-	default:
-		panic("unknown server protocol: " + protoType)
+	factory, ok := serverProtocolFactory(protoType)
+	if !ok {
+		logger.Error("unknown server protocol", "protocol", protoType)
+		return &serveError{err: fmt.Errorf("unknown server protocol: %s", protoType), panic: true}
+	}
+	protoConfig := &ServerProtocolConfig{
+		Plugins:     pluginSet,
+		Listener:    listener,
+		TLS:         tlsConfig,
+		Stdout:      stdout_r,
+		Stderr:      stderr_r,
+		DoneCh:      doneCh,
+		Logger:      logger,
+		ServeConfig: opts,
+	}
+	server, err := factory(protoConfig)
+	if err != nil {
+		logger.Error("protocol init", "error", err)
+		return fmt.Errorf("error creating plugin protocol: %w", err)
 	}
+	listener = protoConfig.Listener
 
 	// Initialize the servers
 	if err := server.Init(); err != nil {
 		logger.Error("protocol init", "error", err)
-		return
+		return fmt.Errorf("error initializing plugin protocol: %w", err)
 	}
 
 	logger.Debug("plugin address", "network", listener.Addr().Network(), "address", listener.Addr().String())
 
 	// Output the address and service name to stdout so that the client can
-	// bring it up. In test mode, we don't do this because clients will
-	// attach via a reattach config.
-	if opts.Test == nil {
+	// bring it up. In test and debug mode, we don't do this because clients
+	// will attach via a reattach config.
+	reattach := &ReattachConfig{
+		Protocol:        protoType,
+		ProtocolVersion: protoVersion,
+		Addr:            listener.Addr(),
+		Pid:             os.Getpid(),
+		Test:            true,
+		TLS:             reattachTLS,
+	}
+	if s, ok := server.(*GRPCServer); ok {
+		reattach.GRPCBrokerMultiplex = s.muxer.Enabled()
+	}
+	switch {
+	case opts.Debug != nil:
+		if err := printDebugReattach(stdout, opts.Debug.Name, reattach); err != nil {
+			logger.Error("failed to print reattach config", "error", err)
+			return fmt.Errorf("error printing reattach config: %w", err)
+		}
+
+	case opts.Test == nil:
 		const grpcBrokerMultiplexingSupported = true
 		protocolLine := fmt.Sprintf("%d|%d|%s|%s|%s|%s",
 			CoreProtocolVersion,
@@ -439,27 +639,33 @@ func Serve(opts *ServeConfig) {
 		// If the environment variable is set, we assume the client is new enough
 		// to handle a seventh segment, as it should now use
 		// strings.Split(line, "|") and always handle each segment individually.
+		//
+		// Likewise, the eighth segment for stream ID support is only appended
+		// if the client says it can handle it.
 		if os.Getenv(envMultiplexGRPC) != "" {
 			protocolLine += fmt.Sprintf("|%v", grpcBrokerMultiplexingSupported)
+			if os.Getenv(envMultiplexGRPCStreamIDs) != "" {
+				protocolLine += fmt.Sprintf("|%v", grpcBrokerMultiplexingSupported)
+			}
 		}
-		fmt.Printf("%s\n", protocolLine)
-		_ = os.Stdout.Sync()
-	} else if ch := opts.Test.ReattachConfigCh; ch != nil {
+		if _, err := fmt.Fprintf(stdout, "%s\n", protocolLine); err != nil {
+			return fmt.Errorf("error writing handshake: %w", err)
+		}
+		if f, ok := stdout.(*os.File); ok {
+			_ = f.Sync()
+		}
+
+	case opts.Test.ReattachConfigCh != nil:
 		// Send back the reattach config that can be used. This isn't
 		// quite ready if they connect immediately but the client should
 		// retry a few times.
-		ch <- &ReattachConfig{
-			Protocol:        protoType,
-			ProtocolVersion: protoVersion,
-			Addr:            listener.Addr(),
-			Pid:             os.Getpid(),
-			Test:            true,
-		}
+		opts.Test.ReattachConfigCh <- reattach
 	}
 
 	// Eat the interrupts. In test mode we disable this so that go test
-	// can be cancelled properly.
-	if opts.Test == nil {
+	// can be cancelled properly, and in debug mode an interrupt is how the
+	// user stops the plugin.
+	if takeover && opts.Test == nil && opts.Debug == nil {
 		ch := make(chan os.Signal, 1)
 		signal.Notify(ch, os.Interrupt)
 		go func() {
@@ -480,7 +686,7 @@ func Serve(opts *ServeConfig) {
 	// to the normal stdout/stderr so output can show up in test logs. We
 	// also send to the stdio stream so that clients can continue working
 	// if they depend on that.
-	if opts.Test == nil || opts.Test.SyncStdio {
+	if (takeover && opts.Test == nil && opts.Debug == nil) || (opts.Test != nil && opts.Test.SyncStdio) {
 		if opts.Test != nil {
 			// In test mode we need to maintain the original values so we can
 			// reset it.
@@ -491,26 +697,41 @@ func Serve(opts *ServeConfig) {
 		}
 		os.Stdout = stdout_w
 		os.Stderr = stderr_w
+		keepStdio = opts.Test == nil
 	}
 
 	// Accept connections and wait for completion
 	go server.Serve(listener)
 
-	ctx := context.Background()
+	var cancel context.CancelFunc
+	ctx, cancel = context.WithCancel(ctx)
+	defer cancel()
 	if opts.Test != nil && opts.Test.Context != nil {
-		ctx = opts.Test.Context
+		stop := context.AfterFunc(opts.Test.Context, cancel)
+		defer stop()
+	}
+	if opts.Debug != nil {
+		if opts.Debug.Context != nil {
+			stop := context.AfterFunc(opts.Debug.Context, cancel)
+			defer stop()
+		}
+
+		// Stop serving when interrupted.
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt)
+		defer stop()
 	}
 	select {
 	case <-ctx.Done():
 		// Cancellation. We can stop the server by closing the listener.
 		// This isn't graceful at all but this is currently only used by
-		// tests and its our only way to stop.
+		// tests, debug mode and ServeContext, and its our only way to stop.
 		_ = listener.Close()
 
 		// If this is a grpc server, then we also ask the server itself to
 		// end which will kill all connections. There isn't an easy way to do
 		// this for net/rpc currently but net/rpc is more and more unused.
-		if s, ok := server.(*GRPCServer); ok {
+		if s, ok := server.(interface{ Stop() }); ok {
 			s.Stop()
 		}
 
@@ -523,6 +744,23 @@ func Serve(opts *ServeConfig) {
 		// work before extracting this library. However, for years we've done
 		// this so we'll keep this functionality.
 	}
+
+	return nil
+}
+
+// printDebugReattach prints the reattach configuration for a plugin served in
+// debug mode, in a form that can be pasted into the host's environment.
+func printDebugReattach(w io.Writer, name string, reattach *ReattachConfig) error {
+	data, err := json.Marshal(map[string]*ReattachConfig{name: reattach})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "Plugin started in debug mode. To attach to it, set the following\n"+
+		"environment variable when running the host:\n\n"+
+		"\t%s='%s'\n\n"+
+		"Press Ctrl-C to stop the plugin.\n", EnvReattachPlugins, data)
+	return err
 }
 
 func serverListener(unixSocketCfg UnixSocketConfig) (net.Listener, error) {
@@ -649,7 +887,11 @@ func newDeleteFileListener(ln net.Listener, path string) *rmListener {
 	return &rmListener{
 		Listener: ln,
 		close: func() error {
-			return os.Remove(path)
+			// Unix listeners usually remove their socket themselves.
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			return nil
 		},
 	}
 }
@@ -4,8 +4,12 @@
 package plugin
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
+	"errors"
+	"io"
 	"log"
 	"net"
 	"os"
@@ -16,6 +20,9 @@ import (
 	"time"
 
 	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-plugin/internal/plugin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestServer_testMode(t *testing.T) {
@@ -155,6 +162,408 @@ func TestServer_testMode_AutoMTLS(t *testing.T) {
 	<-closeCh
 }
 
+func TestServer_testMode_reattachAutoMTLS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan *ReattachConfig, 1)
+	closeCh := make(chan struct{})
+	go Serve(&ServeConfig{
+		HandshakeConfig: testHandshake,
+		Plugins:         testGRPCPluginMap,
+		GRPCServer:      DefaultGRPCServer,
+		Logger:          hclog.NewNullLogger(),
+		Test: &ServeTestConfig{
+			Context:          ctx,
+			ReattachConfigCh: ch,
+			CloseCh:          closeCh,
+			AutoMTLS:         true,
+		},
+	})
+
+	var config *ReattachConfig
+	select {
+	case config = <-ch:
+	case <-time.After(2000 * time.Millisecond):
+		t.Fatal("should've received reattach")
+	}
+	if config.TLS == nil {
+		t.Fatal("reattach config should include TLS material")
+	}
+
+	// A plaintext client should be refused
+	plaintext := *config
+	plaintext.TLS = nil
+	c := NewClient(&ClientConfig{
+		HandshakeConfig:  testHandshake,
+		Plugins:          testGRPCPluginMap,
+		Reattach:         &plaintext,
+		AllowedProtocols: []Protocol{ProtocolGRPC},
+	})
+	client, err := c.Client()
+	if err == nil {
+		if err := client.Ping(); err == nil {
+			t.Fatal("plaintext ping should error")
+		}
+	}
+
+	// Connect with the TLS material
+	c = NewClient(&ClientConfig{
+		HandshakeConfig:  testHandshake,
+		Plugins:          testGRPCPluginMap,
+		Reattach:         config,
+		AllowedProtocols: []Protocol{ProtocolGRPC},
+	})
+	client, err = c.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	raw, err := client.Dispense("test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n := raw.(testInterface).Double(3); n != 6 {
+		t.Fatalf("bad: %d", n)
+	}
+
+	// ensure brokered connections work over mTLS too
+	if err := raw.(testInterface).Bidirectional(); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	<-closeCh
+}
+
+func TestServer_testMode_reattachMux(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := make(chan *ReattachConfig, 1)
+	closeCh := make(chan struct{})
+	go Serve(&ServeConfig{
+		HandshakeConfig: testHandshake,
+		Plugins:         testGRPCPluginMap,
+		GRPCServer:      DefaultGRPCServer,
+		Logger:          hclog.NewNullLogger(),
+		Test: &ServeTestConfig{
+			Context:             ctx,
+			ReattachConfigCh:    ch,
+			CloseCh:             closeCh,
+			GRPCBrokerMultiplex: true,
+		},
+	})
+
+	var config *ReattachConfig
+	select {
+	case config = <-ch:
+	case <-time.After(2000 * time.Millisecond):
+		t.Fatal("should've received reattach")
+	}
+	if !config.GRPCBrokerMultiplex {
+		t.Fatal("reattach config should multiplex the gRPC broker")
+	}
+
+	// Asking for multiplexing requires the reattach config to have it
+	plain := *config
+	plain.GRPCBrokerMultiplex = false
+	c := NewClient(&ClientConfig{
+		HandshakeConfig:     testHandshake,
+		Plugins:             testGRPCPluginMap,
+		Reattach:            &plain,
+		AllowedProtocols:    []Protocol{ProtocolGRPC},
+		GRPCBrokerMultiplex: true,
+	})
+	if _, err := c.Start(); !errors.Is(err, ErrGRPCBrokerMuxNotSupported) {
+		t.Fatalf("bad: %v", err)
+	}
+
+	c = NewClient(&ClientConfig{
+		HandshakeConfig:     testHandshake,
+		Plugins:             testGRPCPluginMap,
+		Reattach:            config,
+		AllowedProtocols:    []Protocol{ProtocolGRPC},
+		GRPCBrokerMultiplex: true,
+	})
+	client, err := c.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	raw, err := client.Dispense("test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Brokered connections go over the multiplexed connection
+	if err := raw.(testInterface).Bidirectional(); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	<-closeCh
+}
+
+func TestServer_debugMode(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		testServerDebugMode(t, false)
+	})
+	t.Run("mux", func(t *testing.T) {
+		testServerDebugMode(t, true)
+	})
+}
+
+func testServerDebugMode(t *testing.T, multiplex bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Capture stdout to read the printed reattach configuration
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(stdout *os.File) { os.Stdout = stdout }(os.Stdout)
+	os.Stdout = w
+
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		Serve(&ServeConfig{
+			HandshakeConfig: testHandshake,
+			Plugins:         testGRPCPluginMap,
+			GRPCServer:      DefaultGRPCServer,
+			Logger:          hclog.NewNullLogger(),
+			Debug: &ServeDebugConfig{
+				Name:                "test",
+				Context:             ctx,
+				GRPCBrokerMultiplex: multiplex,
+			},
+		})
+	}()
+
+	prefix := EnvReattachPlugins + "='"
+	var value string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, prefix) {
+			value = strings.TrimSuffix(strings.TrimPrefix(line, prefix), "'")
+			break
+		}
+	}
+	if value == "" {
+		t.Fatalf("no reattach config printed: %v", scanner.Err())
+	}
+	t.Setenv(EnvReattachPlugins, value)
+
+	// Multiple hosts should be able to connect one after the other, even if
+	// they ask the plugin to shut down, and each gets a fresh broker session.
+	for i := 0; i < 2; i++ {
+		c := NewClient(&ClientConfig{
+			ReattachName:        "test",
+			HandshakeConfig:     testHandshake,
+			Plugins:             testGRPCPluginMap,
+			AllowedProtocols:    []Protocol{ProtocolGRPC},
+			GRPCBrokerMultiplex: multiplex,
+		})
+		client, err := c.Client()
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if c.ReattachConfig().GRPCBrokerMultiplex != multiplex {
+			t.Fatalf("bad: %#v", c.ReattachConfig())
+		}
+
+		// The first host leaves connection info for an ID the plugin never
+		// dials. The next host allocates the same ID, which must not be
+		// handed the stale info.
+		if i == 0 {
+			if _, err := client.(*GRPCClient).broker.Accept(client.(*GRPCClient).broker.NextId()); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+		}
+
+		raw, err := client.Dispense("test")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := raw.(testInterface).Bidirectional(); err != nil {
+			t.Fatalf("connection %d: %s", i, err)
+		}
+
+		// A second host can't attach to the broker while this one is.
+		streamCtx, streamCancel := context.WithTimeout(ctx, 5*time.Second)
+		stream, err := plugin.NewGRPCBrokerClient(client.(*GRPCClient).Conn).StartStream(streamCtx)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		_, err = stream.Recv()
+		streamCancel()
+		if status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("expected the second broker stream to be rejected, got: %v", err)
+		}
+
+		if err := client.Close(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		c.Kill()
+	}
+
+	cancel()
+	select {
+	case <-doneCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve should have returned")
+	}
+}
+
+func TestServeContext(t *testing.T) {
+	t.Setenv(testHandshake.MagicCookieKey, testHandshake.MagicCookieValue)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	stdout_r, stdout_w := io.Pipe()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ServeContext(ctx, &ServeConfig{
+			HandshakeConfig: testHandshake,
+			Plugins:         testGRPCPluginMap,
+			GRPCServer:      DefaultGRPCServer,
+			Logger:          hclog.NewNullLogger(),
+			Stdout:          stdout_w,
+			Listener:        listener,
+		})
+	}()
+
+	// The handshake goes to our writer rather than os.Stdout
+	line, err := bufio.NewReader(stdout_r).ReadString('\n')
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	parts := strings.Split(strings.TrimSpace(line), "|")
+	if len(parts) < 5 || parts[3] != listener.Addr().String() || parts[4] != string(ProtocolGRPC) {
+		t.Fatalf("bad handshake: %q", line)
+	}
+
+	c := NewClient(&ClientConfig{
+		HandshakeConfig: testHandshake,
+		Plugins:         testGRPCPluginMap,
+		Reattach: &ReattachConfig{
+			Protocol:        ProtocolGRPC,
+			ProtocolVersion: int(testHandshake.ProtocolVersion),
+			Addr:            listener.Addr(),
+			Pid:             os.Getpid(),
+			Test:            true,
+		},
+	})
+	client, err := c.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	raw, err := client.Dispense("test")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if n := raw.(testInterface).Double(3); n != 6 {
+		t.Fatalf("bad: %d", n)
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeContext should return after cancellation")
+	}
+}
+
+func TestServeContext_badCookie(t *testing.T) {
+	t.Setenv(testHandshake.MagicCookieKey, "wrong")
+
+	var stderr bytes.Buffer
+	err := ServeContext(context.Background(), &ServeConfig{
+		HandshakeConfig: testHandshake,
+		Plugins:         testGRPCPluginMap,
+		Stderr:          &stderr,
+	})
+	if err == nil {
+		t.Fatal("should error")
+	}
+	if !strings.Contains(stderr.String(), "This binary is a plugin") {
+		t.Fatalf("bad stderr: %q", stderr.String())
+	}
+}
+
+func TestServeContext_handshakeErrorClosesPipes(t *testing.T) {
+	if _, err := os.Stat("/proc/self/fd"); err != nil {
+		t.Skip("open files can't be counted on this platform")
+	}
+	t.Setenv(testHandshake.MagicCookieKey, testHandshake.MagicCookieValue)
+
+	openFiles := func() int {
+		entries, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		return len(entries)
+	}
+
+	before := openFiles()
+	for i := 0; i < 5; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		err = ServeContext(context.Background(), &ServeConfig{
+			HandshakeConfig: testHandshake,
+			Plugins:         testGRPCPluginMap,
+			GRPCServer:      DefaultGRPCServer,
+			Logger:          hclog.NewNullLogger(),
+			Stdout:          errWriter{},
+			Listener:        listener,
+		})
+		if err == nil {
+			t.Fatal("should error")
+		}
+	}
+
+	// The stdio pipes are closed along with the listener.
+	if after := openFiles(); after > before {
+		t.Fatalf("leaked %d files", after-before)
+	}
+}
+
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestServe_setupErrorReturns(t *testing.T) {
+	t.Setenv(testHandshake.MagicCookieKey, testHandshake.MagicCookieValue)
+
+	// Setup failures other than the handshake checks are logged, and Serve
+	// returns rather than exiting the process.
+	Serve(&ServeConfig{
+		HandshakeConfig: testHandshake,
+		Plugins:         testGRPCPluginMap,
+		Logger:          hclog.NewNullLogger(),
+		TLSProvider: func() (*tls.Config, error) {
+			return nil, errors.New("no certificate")
+		},
+	})
+}
+
 func TestServer_RPC(t *testing.T) {
 	closeCh := make(chan struct{})
 	ctx, cancel := context.WithCancel(context.Background())
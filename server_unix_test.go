@@ -6,11 +6,17 @@
 package plugin
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"os/user"
+	"path"
 	"syscall"
 	"testing"
+
+	hclog "github.com/hashicorp/go-hclog"
 )
 
 func TestUnixSocketGroupPermissions(t *testing.T) {
@@ -48,3 +54,88 @@ func TestUnixSocketGroupPermissions(t *testing.T) {
 		})
 	}
 }
+
+func TestActivationListener(t *testing.T) {
+	// Not activated
+	t.Setenv(envListenPid, "")
+	t.Setenv(envListenFds, "")
+	if _, err := ActivationListener(); err != ErrNotSocketActivated {
+		t.Fatalf("expected ErrNotSocketActivated, got %v", err)
+	}
+
+	// Sockets meant for another process
+	t.Setenv(envListenPid, "1")
+	t.Setenv(envListenFds, "1")
+	if _, err := ActivationListener(); err != ErrNotSocketActivated {
+		t.Fatalf("expected ErrNotSocketActivated, got %v", err)
+	}
+
+	// Simulate the service manager passing us a socket. We can't rely on fd 3
+	// being free in the test process, so we pass the descriptor we have.
+	ln, err := net.Listen("unix", path.Join(t.TempDir(), "plugin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	f, err := ln.(*net.UnixListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = f.Close() }()
+
+	// activationListener takes ownership of the descriptor it is passed.
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(envListenPid, fmt.Sprintf("%d", os.Getpid()))
+	t.Setenv(envListenFds, "1")
+	activated, err := activationListener(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv(envListenFds) != "" {
+		t.Fatal("activation environment should be unset")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	t.Setenv(testHandshake.MagicCookieKey, testHandshake.MagicCookieValue)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ServeContext(ctx, &ServeConfig{
+			HandshakeConfig: testHandshake,
+			Plugins:         testGRPCPluginMap,
+			GRPCServer:      DefaultGRPCServer,
+			Logger:          hclog.NewNullLogger(),
+			Stdout:          io.Discard,
+			Listener:        activated,
+		})
+	}()
+
+	c := NewClient(&ClientConfig{
+		HandshakeConfig: testHandshake,
+		Plugins:         testGRPCPluginMap,
+		Reattach: &ReattachConfig{
+			Protocol:        ProtocolGRPC,
+			ProtocolVersion: int(testHandshake.ProtocolVersion),
+			Addr:            ln.Addr(),
+			Pid:             os.Getpid(),
+			Test:            true,
+		},
+	})
+	client, err := c.Client()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := client.Ping(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrSharedMemoryUnsupported is returned by the shared memory functions on
+// platforms other than Linux.
+var ErrSharedMemoryUnsupported = errors.New("shared memory is only supported on Linux")
+
+// SharedMemory is a memory region shared between the host and a plugin,
+// for passing large buffers without copying them through RPC messages.
+//
+// One side creates the region with NewSharedMemory and passes it with
+// ServeSharedMemory, and the other side maps it with OpenSharedMemory. Both
+// sides then read and write the same bytes. The region doesn't synchronize
+// them: they usually tell each other which part of the region they wrote
+// in their RPC calls, for example with an offset and a length.
+type SharedMemory struct {
+	file *os.File
+	data []byte
+
+	once sync.Once
+	err  error
+}
+
+// Bytes returns the shared region. It must not be used after Close.
+func (m *SharedMemory) Bytes() []byte {
+	return m.data
+}
+
+// Size returns the size of the region in bytes.
+func (m *SharedMemory) Size() int {
+	return len(m.data)
+}
+
+// Close unmaps the region. The memory is freed once both sides closed it.
+func (m *SharedMemory) Close() error {
+	m.once.Do(func() {
+		m.err = errors.Join(munmapSharedMemory(m.data), m.file.Close())
+		m.data = nil
+	})
+	return m.err
+}
+
+// NewSharedMemory creates a shared memory region of the given size. The
+// region is backed by an anonymous memory file and is zeroed.
+func NewSharedMemory(size int) (*SharedMemory, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid shared memory size %d", size)
+	}
+
+	f, err := createSharedMemoryFile(size)
+	if err != nil {
+		return nil, err
+	}
+	return mapSharedMemory(f, size)
+}
+
+// ServeSharedMemory passes m to the other side of the broker, and returns
+// the ID to send it, for example in an RPC argument. The other side maps it
+// with OpenSharedMemory.
+//
+// The file descriptor of the region is passed over a Unix socket created
+// for the transfer, so both sides must run on the same machine. m stays
+// usable by this side, and should be closed once it is no longer needed.
+func ServeSharedMemory(b StreamBroker, m *SharedMemory) uint32 {
+	id := b.NextId()
+
+	// Pass a duplicate of the file, so m can be closed at any time.
+	f, dupErr := dupSharedMemoryFile(m.file)
+	size := m.Size()
+	go func() {
+		if f != nil {
+			defer func() { _ = f.Close() }()
+		}
+
+		conn, err := b.AcceptStream(id)
+		if err != nil {
+			log.Printf("[ERR] plugin: serving shared memory %d: %s", id, err)
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		err = dupErr
+		if err == nil {
+			err = serveSharedMemory(conn, f, size, timeoutOf(b))
+		}
+		if err != nil {
+			log.Printf("[ERR] plugin: serving shared memory %d: %s", id, err)
+			_ = writeFrame(conn, frameError, []byte(err.Error()))
+		}
+	}()
+
+	return id
+}
+
+// serveSharedMemory sends the path of a Unix socket over conn, and passes
+// f and the size of its region to the first connection to it.
+func serveSharedMemory(conn net.Conn, f *os.File, size int, timeout time.Duration) error {
+	// The socket is in a private directory, so only processes of the same
+	// user can connect to it.
+	dir, err := os.MkdirTemp("", "plugin-shm")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: filepath.Join(dir, "shm.sock"), Net: "unix"})
+	if err != nil {
+		return err
+	}
+	defer func() { _ = ln.Close() }()
+
+	if err := writeFrame(conn, frameData, []byte(ln.Addr().String())); err != nil {
+		return err
+	}
+
+	if err := ln.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	uc, err := ln.AcceptUnix()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = uc.Close() }()
+
+	if err := sendSharedMemory(uc, f, size); err != nil {
+		return err
+	}
+
+	// Wait for the other side to map the region, so the file stays open
+	// until then.
+	_, _, err = readFrame(conn)
+	return unexpectedEOF(err)
+}
+
+// OpenSharedMemory maps the region passed by the other side with
+// ServeSharedMemory under the given ID. The region must be closed once it
+// is no longer needed.
+func OpenSharedMemory(b StreamBroker, id uint32) (*SharedMemory, error) {
+	conn, err := b.DialStream(id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	typ, payload, err := readFrame(conn)
+	if err != nil {
+		return nil, fmt.Errorf("opening shared memory: %w", unexpectedEOF(err))
+	}
+	if typ == frameError {
+		return nil, &BasicError{Message: string(payload)}
+	}
+
+	uc, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: string(payload), Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = uc.Close() }()
+
+	m, err := receiveSharedMemory(uc)
+	if err != nil {
+		return nil, err
+	}
+	_ = writeFrame(conn, frameEOF, nil)
+
+	return m, nil
+}
+
+// timeoutOf returns the timeout of the broker behind b.
+func timeoutOf(b StreamBroker) time.Duration {
+	switch b := b.(type) {
+	case *MuxBroker:
+		return b.registry.timeout()
+	case *GRPCBroker:
+		return b.registry.timeout()
+	}
+	return defaultBrokerTimeout
+}
@@ -0,0 +1,133 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package plugin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// sharedMemorySeals are the seals a received memfd must have, so that the
+// other side can't resize it under our mapping, or remove the seals later.
+const sharedMemorySeals = unix.F_SEAL_SHRINK | unix.F_SEAL_GROW | unix.F_SEAL_SEAL
+
+// createSharedMemoryFile creates a memfd of the given size. Its size is
+// sealed, so neither side can shrink it under the other's mapping.
+func createSharedMemoryFile(size int) (*os.File, error) {
+	fd, err := unix.MemfdCreate("go-plugin", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return nil, fmt.Errorf("memfd_create: %w", err)
+	}
+	f := os.NewFile(uintptr(fd), "go-plugin-shm")
+
+	if err := unix.Ftruncate(fd, int64(size)); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("ftruncate: %w", err)
+	}
+	if _, err := unix.FcntlInt(uintptr(fd), unix.F_ADD_SEALS, sharedMemorySeals); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("sealing memfd: %w", err)
+	}
+
+	return f, nil
+}
+
+// mapSharedMemory maps size bytes of f.
+func mapSharedMemory(f *os.File, size int) (*SharedMemory, error) {
+	data, err := unix.Mmap(int(f.Fd()), 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("mmap: %w", err)
+	}
+	return &SharedMemory{file: f, data: data}, nil
+}
+
+func munmapSharedMemory(data []byte) error {
+	if data == nil {
+		return nil
+	}
+	return unix.Munmap(data)
+}
+
+// dupSharedMemoryFile duplicates the file of a region.
+func dupSharedMemoryFile(f *os.File) (*os.File, error) {
+	fd, err := unix.FcntlInt(f.Fd(), unix.F_DUPFD_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("dup: %w", err)
+	}
+	return os.NewFile(uintptr(fd), f.Name()), nil
+}
+
+// sendSharedMemory passes f, whose region is size bytes, over uc.
+func sendSharedMemory(uc *net.UnixConn, f *os.File, size int) error {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(size))
+	_, _, err := uc.WriteMsgUnix(buf[:], unix.UnixRights(int(f.Fd())), nil)
+	return err
+}
+
+// receiveSharedMemory receives a file passed with sendSharedMemory, and
+// maps it once it checked that the file is sealed to the advertised size.
+func receiveSharedMemory(uc *net.UnixConn) (*SharedMemory, error) {
+	buf := make([]byte, 8)
+	oob := make([]byte, unix.CmsgSpace(4))
+	n, oobn, _, _, err := uc.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) != 1 {
+		return nil, fmt.Errorf("expected 1 control message, got %d", len(msgs))
+	}
+	fds, err := unix.ParseUnixRights(&msgs[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(fds) != 1 {
+		for _, fd := range fds {
+			_ = unix.Close(fd)
+		}
+		return nil, fmt.Errorf("expected 1 file descriptor, got %d", len(fds))
+	}
+	unix.CloseOnExec(fds[0])
+	f := os.NewFile(uintptr(fds[0]), "go-plugin-shm")
+
+	if n != len(buf) {
+		_ = f.Close()
+		return nil, fmt.Errorf("expected the shared memory size, got %d bytes", n)
+	}
+	size := binary.BigEndian.Uint64(buf)
+
+	seals, err := unix.FcntlInt(uintptr(fds[0]), unix.F_GET_SEALS, 0)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("getting memfd seals: %w", err)
+	}
+	if seals&sharedMemorySeals != sharedMemorySeals {
+		_ = f.Close()
+		return nil, fmt.Errorf("shared memory isn't sealed, seals %#x", seals)
+	}
+
+	var stat unix.Stat_t
+	if err := unix.Fstat(fds[0], &stat); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("fstat: %w", err)
+	}
+	if stat.Size <= 0 || uint64(stat.Size) != size {
+		_ = f.Close()
+		return nil, fmt.Errorf("invalid shared memory size %d, expected %d", stat.Size, size)
+	}
+
+	return mapSharedMemory(f, int(stat.Size))
+}
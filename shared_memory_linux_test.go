@@ -0,0 +1,131 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build linux
+
+package plugin
+
+import (
+	"net"
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSharedMemory(t *testing.T) {
+	t.Run("netrpc", func(t *testing.T) {
+		client, server := testMuxBrokers(t, nil)
+		testSharedMemory(t, server, client)
+	})
+	t.Run("grpc", func(t *testing.T) {
+		client, server := TestPluginGRPCConn(t, true, map[string]Plugin{
+			"test": new(testGRPCInterfacePlugin),
+		})
+		defer func() { _ = client.Close() }()
+		defer server.Stop()
+
+		testSharedMemory(t, server.broker, client.broker)
+	})
+}
+
+func testSharedMemory(t *testing.T, local, remote StreamBroker) {
+	const size = 4 * 1024 * 1024
+
+	m, err := NewSharedMemory(size)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer func() { _ = m.Close() }()
+	copy(m.Bytes(), "from the plugin")
+
+	rm, err := OpenSharedMemory(remote, ServeSharedMemory(local, m))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer func() { _ = rm.Close() }()
+
+	if rm.Size() != size {
+		t.Fatalf("bad: %d", rm.Size())
+	}
+	if actual := string(rm.Bytes()[:15]); actual != "from the plugin" {
+		t.Fatalf("bad: %q", actual)
+	}
+
+	// Writes are visible to the other side without passing anything.
+	copy(rm.Bytes()[size-8:], "the host")
+	if actual := string(m.Bytes()[size-8:]); actual != "the host" {
+		t.Fatalf("bad: %q", actual)
+	}
+
+	// Neither side can shrink the region under the other.
+	if err := unix.Ftruncate(int(rm.file.Fd()), 0); err == nil {
+		t.Fatal("expected the region's size to be sealed")
+	}
+}
+
+func TestSharedMemory_invalidSize(t *testing.T) {
+	if _, err := NewSharedMemory(0); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestSharedMemory_receiveChecks(t *testing.T) {
+	sealed, err := createSharedMemoryFile(4096)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer func() { _ = sealed.Close() }()
+
+	fd, err := unix.MemfdCreate("go-plugin-test", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	unsealed := os.NewFile(uintptr(fd), "unsealed")
+	defer func() { _ = unsealed.Close() }()
+	if err := unix.Ftruncate(fd, 4096); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for name, tc := range map[string]struct {
+		f    *os.File
+		size int
+		ok   bool
+	}{
+		"sealed":        {sealed, 4096, true},
+		"unsealed":      {unsealed, 4096, false},
+		"size mismatch": {sealed, 8192, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			send, recv := testUnixConn(t, fds[0]), testUnixConn(t, fds[1])
+
+			if err := sendSharedMemory(send, tc.f, tc.size); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			m, err := receiveSharedMemory(recv)
+			if m != nil {
+				_ = m.Close()
+			}
+			if (err == nil) != tc.ok {
+				t.Fatalf("bad: %v", err)
+			}
+		})
+	}
+}
+
+// testUnixConn returns a *net.UnixConn for the socket fd.
+func testUnixConn(t *testing.T, fd int) *net.UnixConn {
+	f := os.NewFile(uintptr(fd), "socket")
+	defer func() { _ = f.Close() }()
+
+	conn, err := net.FileConn(f)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn.(*net.UnixConn)
+}
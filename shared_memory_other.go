@@ -0,0 +1,36 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !linux
+
+package plugin
+
+import (
+	"net"
+	"os"
+)
+
+func createSharedMemoryFile(int) (*os.File, error) {
+	return nil, ErrSharedMemoryUnsupported
+}
+
+func mapSharedMemory(f *os.File, _ int) (*SharedMemory, error) {
+	_ = f.Close()
+	return nil, ErrSharedMemoryUnsupported
+}
+
+func munmapSharedMemory([]byte) error {
+	return nil
+}
+
+func dupSharedMemoryFile(*os.File) (*os.File, error) {
+	return nil, ErrSharedMemoryUnsupported
+}
+
+func sendSharedMemory(*net.UnixConn, *os.File, int) error {
+	return ErrSharedMemoryUnsupported
+}
+
+func receiveSharedMemory(*net.UnixConn) (*SharedMemory, error) {
+	return nil, ErrSharedMemoryUnsupported
+}
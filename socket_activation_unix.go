@@ -0,0 +1,66 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !windows
+
+package plugin
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// listenFdsStart is the first file descriptor passed by the service manager,
+// after stdin, stdout and stderr.
+const listenFdsStart = 3
+
+// ActivationListener returns the listening socket passed to the process by a
+// service manager using the systemd socket activation protocol. It is meant
+// to be used as ServeConfig.Listener, so that long-running plugins can be
+// started on demand by the service manager and attached by hosts through a
+// ReattachConfig.
+//
+// Exactly one socket must be passed. If the process was not socket activated,
+// ErrNotSocketActivated is returned. The activation environment variables are
+// unset so that they are not inherited by child processes.
+func ActivationListener() (net.Listener, error) {
+	return activationListener(listenFdsStart)
+}
+
+func activationListener(fdStart int) (net.Listener, error) {
+	pid, fds := os.Getenv(envListenPid), os.Getenv(envListenFds)
+	_ = os.Unsetenv(envListenPid)
+	_ = os.Unsetenv(envListenFds)
+	_ = os.Unsetenv(envListenFdNames)
+
+	if pid == "" || fds == "" {
+		return nil, ErrNotSocketActivated
+	}
+	if pid != strconv.Itoa(os.Getpid()) {
+		// The sockets were meant for another process, most likely our parent.
+		return nil, ErrNotSocketActivated
+	}
+
+	n, err := strconv.Atoi(fds)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value %q: %w", envListenFds, fds, err)
+	}
+	if n != 1 {
+		return nil, fmt.Errorf("expected 1 activation socket, got %d", n)
+	}
+
+	syscall.CloseOnExec(fdStart)
+	f := os.NewFile(uintptr(fdStart), "activation-socket")
+	defer f.Close()
+
+	// FileListener duplicates the descriptor, so we're free to close f.
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("error using activation socket: %w", err)
+	}
+
+	return l, nil
+}
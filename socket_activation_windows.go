@@ -0,0 +1,18 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build windows
+
+package plugin
+
+import (
+	"net"
+)
+
+// ActivationListener returns the listening socket passed to the process by a
+// service manager using the systemd socket activation protocol. Socket
+// activation is not supported on Windows, so this always returns
+// ErrNotSocketActivated.
+func ActivationListener() (net.Listener, error) {
+	return nil, ErrNotSocketActivated
+}
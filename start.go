@@ -0,0 +1,122 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// StartFuture is the result of Client.StartAsync.
+type StartFuture struct {
+	done chan struct{}
+	addr net.Addr
+	err  error
+}
+
+// Done is closed once the plugin is started, or failed to start.
+func (f *StartFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for the plugin to start, and returns the results of
+// Client.Start.
+func (f *StartFuture) Wait() (net.Addr, error) {
+	<-f.done
+	return f.addr, f.err
+}
+
+// StartAsync starts the plugin like StartContext, without waiting for it.
+// The returned StartFuture reports when the plugin is started.
+func (c *Client) StartAsync(ctx context.Context) *StartFuture {
+	f := &StartFuture{done: make(chan struct{})}
+	go func() {
+		defer close(f.done)
+		f.addr, f.err = c.StartContext(ctx)
+	}()
+	return f
+}
+
+// StartError is the error of a client that failed to start, as returned by
+// StartClients and Manager.Start.
+type StartError struct {
+	// Index is the index of the client in the arguments of StartClients,
+	// or in the clients of the Manager.
+	Index int
+
+	// Name is the name of the client in the Manager, if any.
+	Name string
+
+	Err error
+}
+
+func (e *StartError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("error starting plugin %q: %s", e.Name, e.Err)
+	}
+	return fmt.Sprintf("error starting plugin %d: %s", e.Index, e.Err)
+}
+
+func (e *StartError) Unwrap() error {
+	return e.Err
+}
+
+// StartClients starts the given clients concurrently, at most concurrency
+// at a time, or all at once if concurrency isn't positive. Starting is
+// bounded by ctx, see Client.StartContext.
+//
+// It waits for all the clients and returns the errors of those that failed
+// to start as *StartError values joined with errors.Join, or nil. Clients
+// that failed to start aren't killed.
+func StartClients(ctx context.Context, concurrency int, clients ...*Client) error {
+	return startClients(ctx, concurrency, clients, nil)
+}
+
+// startClients is StartClients, with the names of the clients of a Manager.
+func startClients(ctx context.Context, concurrency int, clients []*Client, names []string) error {
+	if concurrency <= 0 || concurrency > len(clients) {
+		concurrency = len(clients)
+	}
+
+	sem := make(chan struct{}, concurrency)
+	errs := make([]error, len(clients))
+	var wg sync.WaitGroup
+	for i, c := range clients {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, c *Client) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if _, err := c.StartContext(ctx); err != nil {
+				startErr := &StartError{Index: i, Err: err}
+				if names != nil {
+					startErr.Name = names[i]
+				}
+				errs[i] = startErr
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// Start starts the clients of the manager that aren't started yet, as
+// StartClients does.
+func (m *Manager) Start(ctx context.Context, concurrency int) error {
+	m.lock.Lock()
+	clients := make([]*Client, 0, len(m.clients))
+	names := make([]string, 0, len(m.clients))
+	for _, mc := range m.clients {
+		clients = append(clients, mc.client)
+		names = append(names, mc.name)
+	}
+	m.lock.Unlock()
+
+	return startClients(ctx, concurrency, clients, names)
+}
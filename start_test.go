@@ -0,0 +1,98 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClient_StartAsync(t *testing.T) {
+	c := NewClient(&ClientConfig{
+		Cmd:             helperProcess("test-interface"),
+		HandshakeConfig: testHandshake,
+		Plugins:         testPluginMap,
+	})
+	defer c.Kill()
+
+	f := c.StartAsync(context.Background())
+	select {
+	case <-f.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout waiting for the plugin to start")
+	}
+
+	addr, err := f.Wait()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if addr == nil {
+		t.Fatal("address should not be nil")
+	}
+	if c.ReattachConfig() == nil {
+		t.Fatal("plugin should be started")
+	}
+}
+
+func TestStartClients(t *testing.T) {
+	var clients []*Client
+	for _, helper := range []string{"test-interface", "start-timeout", "test-interface"} {
+		c := NewClient(&ClientConfig{
+			Cmd:             helperProcess(helper),
+			StartTimeout:    500 * time.Millisecond,
+			HandshakeConfig: testHandshake,
+			Plugins:         testPluginMap,
+		})
+		defer c.Kill()
+		clients = append(clients, c)
+	}
+
+	err := StartClients(context.Background(), 2, clients...)
+	var startErr *StartError
+	if !errors.As(err, &startErr) {
+		t.Fatalf("bad: %v", err)
+	}
+	if startErr.Index != 1 {
+		t.Fatalf("bad: %#v", startErr)
+	}
+
+	for _, i := range []int{0, 2} {
+		if clients[i].ReattachConfig() == nil {
+			t.Fatalf("client %d should be started", i)
+		}
+	}
+}
+
+func TestManager_Start(t *testing.T) {
+	m := NewManager()
+	defer func() { _ = m.Shutdown(context.Background()) }()
+
+	for name, helper := range map[string]string{
+		"good": "test-interface",
+		"bad":  "start-timeout",
+	} {
+		if _, err := m.NewClient(name, &ClientConfig{
+			Cmd:             helperProcess(helper),
+			StartTimeout:    500 * time.Millisecond,
+			HandshakeConfig: testHandshake,
+			Plugins:         testPluginMap,
+		}); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	err := m.Start(context.Background(), 0)
+	var startErr *StartError
+	if !errors.As(err, &startErr) || startErr.Name != "bad" {
+		t.Fatalf("bad: %v", err)
+	}
+
+	for _, s := range m.Status() {
+		if s.Started != (s.Name == "good") {
+			t.Fatalf("bad: %#v", s)
+		}
+	}
+}
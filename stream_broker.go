@@ -0,0 +1,116 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+)
+
+// StreamBroker brokers raw bidirectional byte streams by unique ID. It is
+// implemented by both MuxBroker and GRPCBroker, so code supporting both
+// protocols can pass files, tunnels or other streams between the host and
+// the plugin the same way:
+//
+//	id := broker.NextId()
+//	go func() {
+//		conn, err := broker.AcceptStream(id)
+//		...
+//	}()
+//	// Send id to the other side, which calls broker.DialStream(id).
+type StreamBroker interface {
+	// NextId returns a unique ID to use next.
+	NextId() uint32
+
+	// AcceptStream waits for the other side to dial the stream with the
+	// given ID, and returns it. It should be called once per ID.
+	AcceptStream(id uint32) (net.Conn, error)
+
+	// DialStream opens the stream with the given ID accepted by the other
+	// side.
+	DialStream(id uint32) (net.Conn, error)
+}
+
+var (
+	_ StreamBroker = (*MuxBroker)(nil)
+	_ StreamBroker = (*GRPCBroker)(nil)
+)
+
+// AcceptStream implements StreamBroker. The stream is a connection to a
+// listener opened for the ID, or a multiplexed stream if the broker is
+// multiplexed, and uses the broker's TLS configuration if any.
+func (b *GRPCBroker) AcceptStream(id uint32) (net.Conn, error) {
+	ln, err := b.Accept(id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = ln.Close() }()
+
+	type acceptResult struct {
+		conn net.Conn
+		err  error
+	}
+	ch := make(chan acceptResult, 1)
+	go func() {
+		conn, err := ln.Accept()
+		ch <- acceptResult{conn, err}
+	}()
+
+	var conn net.Conn
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return nil, r.err
+		}
+		conn = r.conn
+	case <-time.After(b.registry.timeout()):
+		// Closing the listener ends the Accept, unless a connection just
+		// came in.
+		go func() {
+			if r := <-ch; r.conn != nil {
+				_ = r.conn.Close()
+			}
+		}()
+		b.registry.expire(id)
+		return nil, fmt.Errorf("timeout waiting for accept")
+	}
+
+	if b.tls != nil {
+		conn = tls.Server(conn, b.tls)
+	}
+	return b.registry.trackConn(id, BrokerAccept, conn), nil
+}
+
+// DialStream implements StreamBroker.
+func (b *GRPCBroker) DialStream(id uint32) (net.Conn, error) {
+	var conn net.Conn
+	if b.muxer.Enabled() {
+		var err error
+		conn, err = b.muxDial(id)(context.Background(), "")
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		c, err := b.connInfo(id)
+		if err != nil {
+			return nil, err
+		}
+		addr, err := b.connInfoAddr(c)
+		if err != nil {
+			return nil, err
+		}
+		conn, err = net.Dial(addr.Network(), addr.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if b.tls != nil {
+		conn = tls.Client(conn, b.tls)
+	}
+	return b.registry.trackConn(id, BrokerDial, conn), nil
+}
@@ -0,0 +1,71 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"io"
+	"testing"
+)
+
+func TestStreamBroker(t *testing.T) {
+	t.Run("netrpc", func(t *testing.T) {
+		client, server := testMuxBrokers(t, nil)
+		testStreamBrokerEcho(t, server, client)
+		testStreamBrokerEcho(t, client, server)
+	})
+
+	for name, multiplex := range map[string]bool{"grpc": false, "grpc mux": true} {
+		t.Run(name, func(t *testing.T) {
+			client, server := TestPluginGRPCConn(t, multiplex, map[string]Plugin{
+				"test": new(testGRPCInterfacePlugin),
+			})
+			defer func() { _ = client.Close() }()
+			defer server.Stop()
+
+			testStreamBrokerEcho(t, server.broker, client.broker)
+			testStreamBrokerEcho(t, client.broker, server.broker)
+		})
+	}
+}
+
+// testStreamBrokerEcho accepts a stream on one side that echoes what the
+// other side writes to it.
+func testStreamBrokerEcho(t *testing.T, acceptor, dialer StreamBroker) {
+	t.Helper()
+
+	id := acceptor.NextId()
+	errCh := make(chan error, 1)
+	go func() {
+		conn, err := acceptor.AcceptStream(id)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		_, err = io.Copy(conn, conn)
+		errCh <- err
+	}()
+
+	conn, err := dialer.DialStream(id)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(buf) != "hello" {
+		t.Fatalf("bad: %q", buf)
+	}
+
+	_ = conn.Close()
+	if err := <-errCh; err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
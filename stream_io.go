@@ -0,0 +1,337 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+)
+
+// Readers and writers are passed over a StreamBroker stream as frames of a
+// one byte type, a four byte big-endian payload length and the payload.
+const (
+	frameData  byte = 0
+	frameEOF   byte = 1
+	frameError byte = 2
+
+	// streamChunkSize is the largest data frame sent.
+	streamChunkSize = 32 * 1024
+
+	// maxFrameSize is the largest frame accepted, to guard against corrupt
+	// streams.
+	maxFrameSize = 1024 * 1024
+)
+
+// ServeReader serves r to the other side of the broker, and returns the ID
+// to send it, for example in an RPC argument. The other side reads it with
+// OpenReader.
+//
+// r is read as the other side reads, so a slow reader slows r down. Errors
+// other than io.EOF returned by r are returned by the other side's Read.
+// If r is an io.Closer, it is closed once it has been read, or once the
+// other side closes its reader early.
+func ServeReader(b StreamBroker, r io.Reader) uint32 {
+	id := b.NextId()
+	go func() {
+		var closeOnce sync.Once
+		closeReader := func() {
+			if c, ok := r.(io.Closer); ok {
+				closeOnce.Do(func() { _ = c.Close() })
+			}
+		}
+		defer closeReader()
+
+		conn, err := b.AcceptStream(id)
+		if err != nil {
+			log.Printf("[ERR] plugin: serving reader %d: %s", id, err)
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		// The other side never writes, so a read returns once it closes the
+		// reader. Closing r then unblocks a pending Read.
+		go func() {
+			_, _ = conn.Read(make([]byte, 1))
+			closeReader()
+		}()
+
+		buf := make([]byte, streamChunkSize)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				if werr := writeFrame(conn, frameData, buf[:n]); werr != nil {
+					// The other side closed the reader.
+					return
+				}
+			}
+			if err == io.EOF {
+				_ = writeFrame(conn, frameEOF, nil)
+				return
+			}
+			if err != nil {
+				_ = writeFrame(conn, frameError, []byte(err.Error()))
+				return
+			}
+		}
+	}()
+
+	return id
+}
+
+// OpenReader opens the reader served by the other side with ServeReader
+// under the given ID. Closing the reader before it reaches io.EOF cancels
+// the transfer.
+//
+// Errors of the served reader are returned by Read as a *BasicError.
+func OpenReader(b StreamBroker, id uint32) (io.ReadCloser, error) {
+	conn, err := b.DialStream(id)
+	if err != nil {
+		return nil, err
+	}
+	return &streamReader{conn: conn}, nil
+}
+
+// streamReader reads the frames of a served reader.
+type streamReader struct {
+	conn net.Conn
+
+	// buf is what's left of the last data frame.
+	buf []byte
+	err error
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 && r.err == nil {
+		typ, payload, err := readFrame(r.conn)
+		switch {
+		case err != nil:
+			r.err = fmt.Errorf("reading stream: %w", unexpectedEOF(err))
+		case typ == frameData:
+			r.buf = payload
+		case typ == frameEOF:
+			r.err = io.EOF
+		case typ == frameError:
+			r.err = &BasicError{Message: string(payload)}
+		default:
+			r.err = fmt.Errorf("reading stream: unknown frame type %d", typ)
+		}
+	}
+
+	if len(r.buf) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *streamReader) Close() error {
+	return r.conn.Close()
+}
+
+// ServeWriter serves w to the other side of the broker, and returns the ID
+// to send it, for example in an RPC argument. The other side writes to it
+// with OpenWriter.
+//
+// Writes of the other side return once the data is sent, without waiting
+// for w to accept it, so they are only held up by w through the buffering of
+// the connection. The first error returned by w is returned by a later Write
+// of the other side, or by its Close.
+// If w is an io.WriteCloser, it is closed when the other side closes its
+// writer and the error of Close is returned to it. If w has a
+// CloseWithError method, such as *io.PipeWriter, it is called instead if the
+// other side goes away without closing its writer.
+func ServeWriter(b StreamBroker, w io.Writer) uint32 {
+	id := b.NextId()
+	go func() {
+		err := serveWriter(b, id, w)
+		if err != nil {
+			if c, ok := w.(interface{ CloseWithError(error) error }); ok {
+				_ = c.CloseWithError(err)
+			}
+		}
+	}()
+
+	return id
+}
+
+// serveWriter writes the frames of the stream id to w. It returns an error
+// if the stream ended without the other side closing it.
+func serveWriter(b StreamBroker, id uint32, w io.Writer) error {
+	conn, err := b.AcceptStream(id)
+	if err != nil {
+		log.Printf("[ERR] plugin: serving writer %d: %s", id, err)
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	for {
+		typ, payload, err := readFrame(conn)
+		if err != nil {
+			return fmt.Errorf("writer %d: %w", id, unexpectedEOF(err))
+		}
+
+		switch typ {
+		case frameData:
+			if _, err := w.Write(payload); err != nil {
+				_ = writeFrame(conn, frameError, []byte(err.Error()))
+				return err
+			}
+		case frameEOF:
+			if c, ok := w.(io.Closer); ok {
+				if err := c.Close(); err != nil {
+					_ = writeFrame(conn, frameError, []byte(err.Error()))
+					return nil
+				}
+			}
+			_ = writeFrame(conn, frameEOF, nil)
+			return nil
+		default:
+			err := fmt.Errorf("unknown frame type %d", typ)
+			_ = writeFrame(conn, frameError, []byte(err.Error()))
+			return err
+		}
+	}
+}
+
+// OpenWriter opens the writer served by the other side with ServeWriter
+// under the given ID. The writer must be closed once everything has been
+// written; Close returns once the served writer has been closed.
+//
+// Errors of the served writer are returned by Write and Close as a
+// *BasicError.
+func OpenWriter(b StreamBroker, id uint32) (io.WriteCloser, error) {
+	conn, err := b.DialStream(id)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &streamWriter{
+		conn:   conn,
+		doneCh: make(chan struct{}),
+	}
+	go w.readStatus()
+	return w, nil
+}
+
+// streamWriter writes frames to a served writer.
+type streamWriter struct {
+	conn net.Conn
+
+	// doneCh is closed once the status of the served writer is read into
+	// err.
+	doneCh chan struct{}
+	err    error
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// readStatus waits for the served writer to report an error, or to
+// acknowledge Close.
+func (w *streamWriter) readStatus() {
+	defer close(w.doneCh)
+
+	typ, payload, err := readFrame(w.conn)
+	switch {
+	case err != nil:
+		w.err = fmt.Errorf("writing stream: %w", unexpectedEOF(err))
+	case typ == frameEOF:
+	case typ == frameError:
+		w.err = &BasicError{Message: string(payload)}
+	default:
+		w.err = fmt.Errorf("writing stream: unknown frame type %d", typ)
+	}
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		select {
+		case <-w.doneCh:
+			if w.err != nil {
+				return n, w.err
+			}
+			return n, errors.New("writing stream: writer closed")
+		default:
+		}
+
+		chunk := p
+		if len(chunk) > streamChunkSize {
+			chunk = chunk[:streamChunkSize]
+		}
+		if err := writeFrame(w.conn, frameData, chunk); err != nil {
+			// Prefer the reason the other side gave for going away.
+			<-w.doneCh
+			if w.err != nil {
+				return n, w.err
+			}
+			return n, err
+		}
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
+
+func (w *streamWriter) Close() error {
+	w.closeOnce.Do(func() {
+		select {
+		case <-w.doneCh:
+		default:
+			if err := writeFrame(w.conn, frameEOF, nil); err != nil {
+				_ = w.conn.Close()
+			}
+		}
+		<-w.doneCh
+		w.closeErr = w.err
+		_ = w.conn.Close()
+	})
+	return w.closeErr
+}
+
+func writeFrame(w io.Writer, typ byte, payload []byte) error {
+	var header [5]byte
+	header[0] = typ
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	if len(payload) == 0 {
+		return nil
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("frame of %d bytes is too large", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, unexpectedEOF(err)
+	}
+	return header[0], payload, nil
+}
+
+// unexpectedEOF turns io.EOF into io.ErrUnexpectedEOF, for streams that
+// ended without an EOF frame.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,152 @@
+// Copyright IBM Corp. 2016, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package plugin
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestStreamIO(t *testing.T) {
+	t.Run("netrpc", func(t *testing.T) {
+		client, server := testMuxBrokers(t, nil)
+		testStreamIO(t, server, client)
+	})
+	t.Run("grpc", func(t *testing.T) {
+		client, server := TestPluginGRPCConn(t, false, map[string]Plugin{
+			"test": new(testGRPCInterfacePlugin),
+		})
+		defer func() { _ = client.Close() }()
+		defer server.Stop()
+
+		testStreamIO(t, server.broker, client.broker)
+	})
+}
+
+// testWriteCloser is a buffer recording whether it was closed.
+type testWriteCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (w *testWriteCloser) Close() error {
+	w.closed = true
+	return nil
+}
+
+func testStreamIO(t *testing.T, local, remote StreamBroker) {
+	data := make([]byte, 1024*1024+17)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	t.Run("reader", func(t *testing.T) {
+		r, err := OpenReader(remote, ServeReader(local, bytes.NewReader(data)))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		defer func() { _ = r.Close() }()
+
+		actual, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !bytes.Equal(actual, data) {
+			t.Fatalf("read %d bytes, expected %d", len(actual), len(data))
+		}
+	})
+
+	t.Run("reader error", func(t *testing.T) {
+		src := io.MultiReader(strings.NewReader("hello"), iotest.ErrReader(errors.New("disk on fire")))
+		r, err := OpenReader(remote, ServeReader(local, src))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		defer func() { _ = r.Close() }()
+
+		actual, err := io.ReadAll(r)
+		if string(actual) != "hello" {
+			t.Fatalf("bad: %q", actual)
+		}
+		var basicErr *BasicError
+		if !errors.As(err, &basicErr) || basicErr.Message != "disk on fire" {
+			t.Fatalf("bad: %#v", err)
+		}
+	})
+
+	t.Run("reader cancel", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		r, err := OpenReader(remote, ServeReader(local, pr))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		_ = r.Close()
+
+		// Closing the remote reader closes the served pipe, which fails the
+		// writes into it.
+		errCh := make(chan error, 1)
+		go func() {
+			for {
+				if _, err := pw.Write([]byte("hello")); err != nil {
+					errCh <- err
+					return
+				}
+			}
+		}()
+		select {
+		case err := <-errCh:
+			if !errors.Is(err, io.ErrClosedPipe) {
+				t.Fatalf("bad: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("served reader wasn't closed")
+		}
+	})
+
+	t.Run("writer", func(t *testing.T) {
+		dst := new(testWriteCloser)
+		w, err := OpenWriter(remote, ServeWriter(local, dst))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if _, err := io.Copy(w, bytes.NewReader(data)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if !dst.closed {
+			t.Fatal("served writer wasn't closed")
+		}
+		if !bytes.Equal(dst.Bytes(), data) {
+			t.Fatalf("wrote %d bytes, expected %d", dst.Len(), len(data))
+		}
+	})
+
+	t.Run("writer error", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		_ = pr.CloseWithError(errors.New("disk full"))
+
+		w, err := OpenWriter(remote, ServeWriter(local, pw))
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		// The error comes back with a write or the close.
+		_, _ = w.Write([]byte("hello"))
+		err = w.Close()
+		var basicErr *BasicError
+		if !errors.As(err, &basicErr) || basicErr.Message != "disk full" {
+			t.Fatalf("bad: %#v", err)
+		}
+	})
+}
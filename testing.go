@@ -151,7 +151,7 @@ func TestPluginGRPCConn(t testing.TB, multiplex bool, ps map[string]Plugin) (*GR
 	// Start up the server
 	var muxer *grpcmux.GRPCServerMuxer
 	if multiplex {
-		muxer = grpcmux.NewGRPCServerMuxer(logger, ln)
+		muxer = grpcmux.NewGRPCServerMuxer(logger, ln, true)
 		ln = muxer
 	}
 	server := &GRPCServer{
@@ -171,6 +171,9 @@ func TestPluginGRPCConn(t testing.TB, multiplex bool, ps map[string]Plugin) (*GR
 	client := &Client{
 		address:  ln.Addr(),
 		protocol: ProtocolGRPC,
+
+		grpcMux:          multiplex,
+		grpcMuxStreamIDs: multiplex,
 		config: &ClientConfig{
 			Plugins:             ps,
 			GRPCBrokerMultiplex: multiplex,